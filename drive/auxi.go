@@ -2,10 +2,11 @@ package drive
 
 import (
 	"C"
+	"bytes"
+	"database/sql"
 	"database/sql/driver"
 	"errors"
 	"fmt"
-	"strconv"
 	"strings"
 	"time"
 	"unsafe"
@@ -33,6 +34,10 @@ type __Value struct {
 	// When parameter binding, specify the data type of the field in the table
 	types int
 
+	// Parameter direction, one of SQL_PARAM_INPUT, SQL_PARAM_OUTPUT,
+	// SQL_PARAM_INPUTOUTPUT and SQL_PARAM_RETURNVALUE
+	inout int
+
 	// Return code
 	rcode C.int
 }
@@ -53,9 +58,17 @@ type parse struct {
 
 	Val []__Value
 
+	// Destinations of the output parameters, nil for the inputs, by
+	// position in Val. Val is passed to the C library, it cannot hold
+	// Go pointers
+	targets []interface{}
+
 	// When the parameter binding type is binding
 	// by parameter placeholder, position identifies the parameter position
 	position int
+
+	// Time zone of the session, nil for UTC
+	loc *time.Location
 }
 
 type ParseParam interface {
@@ -74,79 +87,196 @@ type ParseParam interface {
 	assertParamName(string) error
 }
 
-func namedValueToValue(named []driver.NamedValue) ([]driver.Value, error) {
-	__Par := make([]driver.Value, len(named))
+func valueToNamedValue(args []driver.Value) []driver.NamedValue {
+	__Par := make([]driver.NamedValue, len(args))
+
+	for pos, Param := range args {
+		__Par[pos] = driver.NamedValue{Ordinal: pos + 1, Value: Param}
+	}
 
-	for pos, Param := range named {
+	return __Par
+}
+
+// Pointer to the bound data, as expected by the XGC_BindParam* calls
+func (self *__Value) pointer() unsafe.Pointer {
+	if self.islob {
+		return unsafe.Pointer(&self.plob)
+	}
+	return unsafe.Pointer(self.value)
+}
+
+/*
+ * bindParams asserts the data type of every argument and binds it to
+ * the connection handle. Arguments carrying a name are bound through
+ * XGC_BindParamByName against the :name placeholders of the statement,
+ * the others are bound in order of appearance.
+ */
+func (self *parse) bindParams(conn *unsafe.Pointer, query string,
+	args []driver.NamedValue) error {
+
+	if len(args) == 0 {
+		return nil
+	}
+
+	named := 0
+	for _, Param := range args {
 		if len(Param.Name) > 0 {
-			return nil, errors.New("driver does not support the use of Named Parameters")
+			named++
 		}
-		__Par[pos] = Param.Value
 	}
 
-	return __Par, nil
+	if named == len(args) {
+		return self.bindParamsByName(conn, query, args)
+	}
+
+	if named != 0 {
+		return errors.New("named and positional parameters cannot be mixed")
+	}
+
+	for pos, Param := range args {
+		err := self.assertParamType(Param.Value, pos)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(self.Val) != self.assertParamCount(query) {
+		return errors.New("the number of parameters does not match")
+	}
+
+	switch self.assertBindType(query) {
+	case BIND_PARAM_BY_POS:
+		for pos := range self.Val {
+			param := &self.Val[pos]
+			re := cgo_xgc_bindparambypos(conn, pos+1, param.inout,
+				param.types, param.pointer(), param.buff, &param.length)
+			if re < 0 {
+				return xgc_error(conn, "XGC_BindParamByPos", re)
+			}
+		}
+
+	case BIND_PARAM_BY_NAME:
+		_ = self.assertParamName(query)
+		for pos := range self.Val {
+			param := &self.Val[pos]
+			re := cgo_xgc_bindparambyname(conn, self.param_names[pos], param.inout,
+				param.types, param.pointer(), param.buff, &param.rcode, &param.length)
+			if re < 0 {
+				return xgc_error(conn, "XGC_BindParamByName", re)
+			}
+		}
+	}
+
+	return nil
 }
 
-func (self *parse) assertParamType(dV driver.Value, pos int) error {
+/*
+ * bindParamsByName binds sql.Named arguments to the :name placeholders
+ * of the statement. A name used several times in the statement is bound
+ * once; a placeholder without an argument, or an argument without a
+ * placeholder, is reported instead of falling back to binding by position.
+ */
+func (self *parse) bindParamsByName(conn *unsafe.Pointer, query string,
+	args []driver.NamedValue) error {
+
+	if self.assertBindType(query) != BIND_PARAM_BY_NAME {
+		return errors.New("named parameters require :name placeholders in the SQL statement")
+	}
 
-	var dest __Value
-	switch dV.(type) {
+	_ = self.assertParamName(query)
 
-	case int64:
-		srcv, ok := dV.(int64)
-		if !ok {
-			news := errorNews("int64")
-			return errors.New(news)
+	var names []*C.char
+	for _, name := range self.param_names {
+		repeated := false
+		for _, prev := range names {
+			if strings.EqualFold(C.GoString(prev), C.GoString(name)) {
+				repeated = true
+				break
+			}
 		}
 
-		S := strconv.FormatInt(srcv, 10)
-		dest.value = C.CString(S)
-		dest.length = C.int(strings.Count(S, "") - 1)
-		dest.buff = dest.length + 1
-		dest.islob = false
-		dest.types = SQL_XG_C_CHAR
+		if !repeated {
+			names = append(names, name)
+		}
+	}
 
-	case float32:
-		srcv, ok := dV.(float64)
-		if !ok {
-			news := errorNews("float32")
-			return errors.New(news)
+	values := make([]*driver.NamedValue, len(names))
+	for pos := range args {
+		Param := &args[pos]
+
+		found := false
+		for j, name := range names {
+			if !strings.EqualFold(C.GoString(name), Param.Name) {
+				continue
+			}
+
+			if values[j] != nil {
+				return fmt.Errorf("named parameter :%s is given more than once", Param.Name)
+			}
+
+			values[j] = Param
+			found = true
+			break
 		}
 
-		S := strconv.FormatFloat(srcv, 'f', 6, 64)
-		dest.value = C.CString(S)
-		dest.length = C.int(strings.Count(S, "") - 1)
-		dest.buff = dest.length + 1
-		dest.islob = false
-		dest.types = SQL_XG_C_CHAR
+		if !found {
+			return fmt.Errorf("named parameter :%s does not appear in the SQL statement", Param.Name)
+		}
+	}
 
-	case float64:
-		srcv, ok := dV.(float64)
-		if !ok {
-			news := errorNews("float64")
-			return errors.New(news)
+	for pos, name := range names {
+		if values[pos] == nil {
+			return fmt.Errorf("missing value for named parameter :%s", C.GoString(name))
 		}
 
-		S := strconv.FormatFloat(srcv, 'f', 15, 64)
-		dest.value = C.CString(S)
-		dest.length = C.int(strings.Count(S, "") - 1)
-		dest.buff = dest.length + 1
-		dest.islob = false
-		dest.types = SQL_XG_C_CHAR
+		err := self.assertParamType(values[pos].Value, pos)
+		if err != nil {
+			return err
+		}
+	}
 
-	case bool:
-		srcv, ok := dV.(bool)
-		if !ok {
-			news := errorNews("bool")
-			return errors.New(news)
+	for pos := range self.Val {
+		param := &self.Val[pos]
+		re := cgo_xgc_bindparambyname(conn, names[pos], param.inout,
+			param.types, param.pointer(), param.buff, &param.rcode, &param.length)
+		if re < 0 {
+			return xgc_error(conn, "XGC_BindParamByName", re)
 		}
+	}
 
-		S := strconv.FormatBool(srcv)
-		dest.value = C.CString(S)
-		dest.length = C.int(strings.Count(S, "") - 1)
-		dest.buff = dest.length + 1
-		dest.islob = false
-		dest.types = SQL_XG_C_CHAR
+	return nil
+}
+
+// Release the memory held by the bound parameters
+func (self *parse) free() {
+	for _, name := range self.param_names {
+		cgo_c_free(unsafe.Pointer(name))
+	}
+	self.param_names = nil
+
+	for pos := range self.Val {
+		param := &self.Val[pos]
+		if !param.islob {
+			cgo_c_free(unsafe.Pointer(param.value))
+		} else {
+			cgo_xgc_lob_distroy(&param.plob)
+		}
+	}
+	self.Val = nil
+	self.targets = nil
+}
+
+func (self *parse) assertParamType(dV driver.Value, pos int) error {
+
+	var dest __Value
+	var target interface{}
+	dest.inout = SQL_PARAM_INPUT
+
+	switch dV.(type) {
+
+	case int, int8, int16, int32, int64, uint8, uint16, uint32,
+		float32, float64, bool:
+		dest.assertNative(dV)
 
 	case string:
 		srcv, ok := dV.(string)
@@ -174,9 +304,7 @@ func (self *parse) assertParamType(dV driver.Value, pos int) error {
 			return errors.New(news)
 		}
 
-		tm := fmt.Sprintf("%04d-%02d-%02d %02d:%02d:%02d",
-			srcv.Year(), int(srcv.Month()), srcv.Day(),
-			srcv.Hour(), srcv.Minute(), srcv.Second())
+		tm := formatTime(srcv, self.loc)
 
 		dest.value = C.CString(tm)
 		dest.length = C.int(strings.Count(tm, "") - 1)
@@ -185,11 +313,6 @@ func (self *parse) assertParamType(dV driver.Value, pos int) error {
 		dest.types = SQL_XG_C_CHAR
 
 	case []byte:
-		re := cgo_xgc_new_lob(&dest.plob)
-		if re < 0 {
-			return errors.New("Cannot create new large object")
-		}
-
 		srcv, ok := dV.([]byte)
 		if !ok {
 
@@ -197,11 +320,10 @@ func (self *parse) assertParamType(dV driver.Value, pos int) error {
 			return errors.New(news)
 		}
 
-		cgo_xgc_put_lob_data(
-			&dest.plob,
-			unsafe.Pointer((*C.char)(unsafe.Pointer(&srcv[0]))),
-			len(srcv))
-		cgo_xgc_put_lob_data(&dest.plob, nil, -1)
+		err := putLob(&dest.plob, bytes.NewReader(srcv))
+		if err != nil {
+			return err
+		}
 
 		dest.value = nil
 		dest.length = C.int(8)
@@ -209,6 +331,56 @@ func (self *parse) assertParamType(dV driver.Value, pos int) error {
 		dest.islob = true
 		dest.types = SQL_XG_C_BLOB
 
+	case LobReader, *LobReader:
+		var srcv LobReader
+		if pv, ok := dV.(*LobReader); ok {
+			if pv == nil {
+				return self.assertParamType(nil, pos)
+			}
+			srcv = *pv
+		} else {
+			srcv = dV.(LobReader)
+		}
+
+		if srcv.Reader == nil {
+			return errors.New("LobReader without a Reader")
+		}
+
+		err := putLob(&dest.plob, srcv.Reader)
+		if err != nil {
+			return err
+		}
+
+		dest.value = nil
+		dest.length = C.int(8)
+		dest.buff = C.int(8)
+		dest.islob = true
+		dest.types = SQL_XG_C_BLOB
+		if srcv.Clob {
+			dest.types = SQL_XG_C_CLOB
+		}
+
+	case sql.Out:
+		srcv := dV.(sql.Out)
+		inout := SQL_PARAM_OUTPUT
+		if srcv.In {
+			inout = SQL_PARAM_INPUTOUTPUT
+		}
+
+		err := dest.assertOutParam(srcv.Dest, inout, self.loc)
+		if err != nil {
+			return err
+		}
+		target = srcv.Dest
+
+	case ReturnValue:
+		srcv := dV.(ReturnValue)
+		err := dest.assertOutParam(srcv.Dest, SQL_PARAM_RETURNVALUE, self.loc)
+		if err != nil {
+			return err
+		}
+		target = srcv.Dest
+
 	case nil:
 		dest.value = C.CString("xugusql")
 		dest.length = 0
@@ -223,6 +395,7 @@ func (self *parse) assertParamType(dV driver.Value, pos int) error {
 
 	self.position = pos
 	self.Val = append(self.Val, dest)
+	self.targets = append(self.targets, target)
 
 	return nil
 }
@@ -237,49 +410,28 @@ func (self *parse) assertParamCount(query string) int {
 		self.bind_type = self.assertBindType(query)
 	}
 
-	switch self.bind_type {
-	case BIND_PARAM_BY_POS:
-		self.param_count = strings.Count(query, "?")
-	case BIND_PARAM_BY_NAME:
-
-		self.param_count = 0
-		pos := 0
-		phead := -1
-
-		for true {
-			pos = strings.IndexByte(query[phead+1:], ':')
-			if pos == -1 {
-				break
-			}
-
-			pos += phead + 1
-			tmp := pos
-			for tmp > phead {
-				tmp--
-				if query[tmp] == ' ' {
-					continue
-				}
-
-				if query[tmp] == ',' || query[tmp] == '(' {
-					self.param_count++
-				}
-				break
-			}
-			phead = pos
+	self.param_count = 0
+	for _, param := range scanPlaceholders(query) {
+		if (param.name == "") == (self.bind_type == BIND_PARAM_BY_POS) {
+			self.param_count++
 		}
 	}
 
 	return self.param_count
 }
 
+// A statement with a '?' placeholder binds by position, any other by name
 func (self *parse) assertBindType(query string) int {
 
-	self.bind_type = strings.IndexByte(query, '?')
-	if self.bind_type != -1 {
-		return BIND_PARAM_BY_POS
+	self.bind_type = BIND_PARAM_BY_NAME
+	for _, param := range scanPlaceholders(query) {
+		if param.name == "" {
+			self.bind_type = BIND_PARAM_BY_POS
+			break
+		}
 	}
 
-	return BIND_PARAM_BY_NAME
+	return self.bind_type
 }
 
 func (self *parse) assertParamName(query string) error {
@@ -288,38 +440,10 @@ func (self *parse) assertParamName(query string) error {
 		self.assertParamCount(query)
 	}
 
-	pos := 0
-	phead := -1
-
-	for true {
-		pos = strings.IndexByte(query[phead+1:], ':')
-		if pos == -1 {
-			break
-		}
-
-		pos += phead + 1
-		tmp := pos
-		for tmp > phead {
-			tmp--
-			if query[tmp] == ' ' {
-				continue
-			}
-
-			// Parse parameter positions bound by parameter name
-			if query[tmp] == ',' || query[tmp] == '(' {
-				parg := pos
-				for true {
-					parg++
-					if query[parg] == ',' || query[parg] == ')' || query[parg] == ' ' {
-						self.param_names = append(self.param_names, C.CString(query[pos+1:parg]))
-						break
-					}
-				}
-			}
-			break
+	for _, param := range scanPlaceholders(query) {
+		if param.name != "" {
+			self.param_names = append(self.param_names, C.CString(param.name))
 		}
-
-		phead = pos
 	}
 
 	return nil
@@ -0,0 +1,277 @@
+package drive
+
+import (
+	"C"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"unsafe"
+)
+
+// BATCH_SIZE is the number of rows ExecBatch sends to the server
+// in a single XGC_ExecBatch call, ExecBatchSize takes another size
+const BATCH_SIZE int = 1000
+
+// BatchResult summarizes the execution of ExecBatch
+type BatchResult struct {
+	// Number of affected rows reported for each batch, in order. After
+	// a failure the last entry counts the rows of the failing batch
+	// executed before the failing row
+	RowsAffected []int64
+}
+
+// Total returns the number of rows affected by all the batches
+func (self *BatchResult) Total() int64 {
+	var total int64
+	for _, affected := range self.RowsAffected {
+		total += affected
+	}
+	return total
+}
+
+// BatchError reports the row that failed. The server rejects a batch as
+// a whole, the failing batch is run again in halves to find the row;
+// the rows before it have been executed, the following ones have not.
+type BatchError struct {
+	// Index of the failing batch
+	Batch int
+	// Index of the first row of the failing batch in the rows
+	// passed to ExecBatch
+	FirstRow int
+	// Number of rows of the failing batch
+	Rows int
+	// Index of the failing row in the rows passed to ExecBatch
+	Row int
+
+	Err error
+}
+
+func (self *BatchError) Error() string {
+	return fmt.Sprintf("batch %d failed at row %d: %v", self.Batch, self.Row, self.Err)
+}
+
+func (self *BatchError) Unwrap() error {
+	return self.Err
+}
+
+/*
+ * ExecBatch executes query once for every element of rows. The values
+ * are bound column by column with XGC_BindParamArrayByPos (or ByName for
+ * :name placeholders) and sent BATCH_SIZE rows at a time with
+ * XGC_ExecBatch, instead of one round trip per row:
+ *
+ *	conn, _ := db.Conn(ctx)
+ *	res, err := drive.ExecBatch(ctx, conn, "INSERT INTO t VALUES(?, ?)", rows)
+ *
+ * On failure the returned BatchResult holds the batches that succeeded
+ * and the error is a *BatchError giving the failing row.
+ */
+func ExecBatch(ctx context.Context, conn *sql.Conn, query string,
+	rows [][]interface{}) (*BatchResult, error) {
+	return ExecBatchSize(ctx, conn, query, rows, BATCH_SIZE)
+}
+
+// ExecBatchSize is ExecBatch sending size rows per XGC_ExecBatch call.
+func ExecBatchSize(ctx context.Context, conn *sql.Conn, query string,
+	rows [][]interface{}, size int) (*BatchResult, error) {
+
+	if size <= 0 {
+		return nil, fmt.Errorf("invalid batch size %d", size)
+	}
+
+	var result *BatchResult
+
+	err := conn.Raw(func(driverConn interface{}) error {
+		xc, ok := driverConn.(*xugusqlConn)
+		if !ok {
+			return errors.New("ExecBatch requires a xugusql connection")
+		}
+
+		value, err := xc.watchCancel(ctx, func() (interface{}, error) {
+			return xc.execBatch(query, rows, size)
+		})
+		if value != nil {
+			result = value.(*BatchResult)
+		}
+
+		return err
+	})
+
+	return result, err
+}
+
+func (self *xugusqlConn) execBatch(query string,
+	rows [][]interface{}, size int) (*BatchResult, error) {
+
+	result := &BatchResult{}
+	if len(rows) == 0 {
+		return result, nil
+	}
+
+	parser := &parse{
+		bind_type:   0,
+		param_count: 0,
+		position:    0,
+		loc:         self.loc,
+	}
+
+	columns := parser.assertParamCount(query)
+	for pos, row := range rows {
+		if len(row) != columns {
+			return result, fmt.Errorf("row %d has %d values, the statement expects %d",
+				pos, len(row), columns)
+		}
+	}
+
+	var names []*C.char
+	if parser.bind_type == BIND_PARAM_BY_NAME {
+		_ = parser.assertParamName(query)
+		names = parser.param_names
+	}
+	defer parser.free()
+
+	sql := C.CString(query)
+	defer func() {
+		cgo_c_free(unsafe.Pointer(sql))
+		cgo_xgc_reset_params(self.handle())
+	}()
+
+	for first := 0; first < len(rows); first += size {
+		last := first + size
+		if last > len(rows) {
+			last = len(rows)
+		}
+
+		affected, err := self.execBatchRows(sql, names, rows[first:last], columns)
+		if err != nil {
+			var row int
+			affected, row, err = self.narrowBatch(sql, names, rows[first:last], columns, err)
+			if err != nil {
+				result.RowsAffected = append(result.RowsAffected, affected)
+				return result, &BatchError{
+					Batch:    len(result.RowsAffected) - 1,
+					FirstRow: first,
+					Rows:     last - first,
+					Row:      first + row,
+					Err:      err,
+				}
+			}
+		}
+
+		result.RowsAffected = append(result.RowsAffected, affected)
+	}
+
+	return result, nil
+}
+
+/*
+ * narrowBatch finds the row of a failed batch the server rejects. The
+ * batch is split in halves, the first half is executed and the search
+ * goes on in the half that fails, until a single row is left. It
+ * returns the rows affected before that row, its index in rows and its
+ * error; no error when every part of the batch succeeds on its own.
+ */
+func (self *xugusqlConn) narrowBatch(sql *C.char, names []*C.char,
+	rows [][]interface{}, columns int, err error) (int64, int, error) {
+
+	var affected int64
+	first := 0
+
+	for len(rows) > 1 {
+		half := len(rows) / 2
+
+		n, herr := self.execBatchRows(sql, names, rows[:half], columns)
+		if herr != nil {
+			rows, err = rows[:half], herr
+			continue
+		}
+
+		affected += n
+		rows = rows[half:]
+		first += half
+
+		n, herr = self.execBatchRows(sql, names, rows, columns)
+		if herr == nil {
+			return affected + n, 0, nil
+		}
+		err = herr
+	}
+
+	return affected, first, err
+}
+
+// Bind the column arrays of one batch and execute it
+func (self *xugusqlConn) execBatchRows(sql *C.char, names []*C.char,
+	rows [][]interface{}, columns int) (int64, error) {
+
+	var buffers []unsafe.Pointer
+	defer func() {
+		for _, buff := range buffers {
+			cgo_c_free(buff)
+		}
+	}()
+
+	count := len(rows)
+	for col := 0; col < columns; col++ {
+
+		// The texts of the column, NULL values have no text
+		texts := make([]*string, count)
+		width := 1
+		for pos, row := range rows {
+			value, err := convertValue(row[col])
+			if err != nil {
+				return 0, fmt.Errorf("row %d, column %d: %v", pos, col+1, err)
+			}
+
+			if value == nil {
+				continue
+			}
+
+			S := valueToText(value, self.loc)
+			texts[pos] = &S
+			if len(S)+1 > width {
+				width = len(S) + 1
+			}
+		}
+
+		array := cgo_c_calloc(uint(width * count))
+		lengths := cgo_c_calloc(uint(count) * uint(unsafe.Sizeof(C.int(0))))
+		buffers = append(buffers, unsafe.Pointer(array), unsafe.Pointer(lengths))
+
+		data := unsafe.Slice((*byte)(unsafe.Pointer(array)), width*count)
+		rlen := unsafe.Slice((*C.int)(unsafe.Pointer(lengths)), count)
+		for pos, S := range texts {
+			if S == nil {
+				continue
+			}
+
+			copy(data[pos*width:], *S)
+			rlen[pos] = C.int(len(*S))
+			if rlen[pos] == 0 {
+				rlen[pos] = 1
+			}
+		}
+
+		if names != nil {
+			re := cgo_xgc_bindparamarraybyname(self.handle(), names[col], columns, SQL_PARAM_INPUT,
+				SQL_XG_C_CHAR, count, unsafe.Pointer(array), width, &rlen[0])
+			if re < 0 {
+				return 0, self.get_error("XGC_BindParamArrayByName", re)
+			}
+		} else {
+			re := cgo_xgc_bindparamarraybypos(self.handle(), col+1, columns, SQL_PARAM_INPUT,
+				SQL_XG_C_CHAR, count, unsafe.Pointer(array), width, &rlen[0])
+			if re < 0 {
+				return 0, self.get_error("XGC_BindParamArrayByPos", re)
+			}
+		}
+	}
+
+	re := cgo_xgc_exec_batch(self.handle(), sql, count)
+	if re < 0 {
+		return 0, self.get_error("XGC_ExecBatch", re)
+	}
+
+	return int64(re), nil
+}
@@ -14,14 +14,17 @@ import (
 */
 import "C"
 
-var IPS_COUNTER int = 0
-var IPS_BODY unsafe.Pointer
-
 /* Collect error information from the database server */
 func cgo_xgc_error(__pConn *unsafe.Pointer, pLog *C.char, act *C.int) int {
 	return int(C.XGC_GetError(__pConn, pLog, act))
 }
 
+/* Collect the error code, return code and message from the database server */
+func cgo_xgc_error_info(__pConn *unsafe.Pointer, pCode *C.char, pRet *C.int,
+	pLog *C.char, Buff uint, act *C.int) int {
+	return int(C.XGC_GetErrorInfoOption(__pConn, pCode, pRet, pLog, C.int(Buff), act))
+}
+
 /*
  * 'C.XGC_OpenConn' is used to establish a new connection session with XGDB,
  * return value:
@@ -32,12 +35,15 @@ func cgo_xgc_connect(pdsn *C.char, __pConn *unsafe.Pointer) int {
 	return int(C.XGC_OpenConn(pdsn, __pConn))
 }
 
-/* 'C.XGC_OpenConn_Ips' is used to establish a new connection session with XGDB,
- * it is different from'C.XGC_OpenConn' in that'C.XGC_OpenConn_Ips' can achieve
- * connection load balancing between distributed database nodes.
- */
-func cgo_xgc_connect_ips(pdsn *C.char, __pConn *unsafe.Pointer) int {
-	return int(C.XGC_OpenConn_Ips(pdsn, C.int(IPS_COUNTER), &IPS_BODY, __pConn))
+// Get the value of an attribute of the connection.
+func cgo_xgc_get_attr(__pConn *unsafe.Pointer, attr int, pVal unsafe.Pointer,
+	Buff int, Type *C.int, act *C.int) int {
+	return int(C.XGC_GetAttr(__pConn, C.int(attr), pVal, C.int(Buff), Type, act))
+}
+
+// Set the value of an attribute of the connection.
+func cgo_xgc_set_attr(__pConn *unsafe.Pointer, attr int, pVal unsafe.Pointer, Buff int) int {
+	return int(C.XGC_SetAttr(__pConn, C.int(attr), pVal, C.int(Buff)))
 }
 
 /*
@@ -97,6 +103,36 @@ func cgo_xgc_bindparambyname(__pConn *unsafe.Pointer, Name *C.char, ArgType int,
 		Valu, Buff, Rcode, act))
 }
 
+/*
+ * Binding arrays of parameters for batch execution,
+ * the binding method uses the form of placeholders.
+ */
+func cgo_xgc_bindparamarraybypos(__pConn *unsafe.Pointer, seq int, num int, ArgType int,
+	Type int, count int, Valu unsafe.Pointer, Buff int, act *C.int) int {
+	return int(C.XGC_BindParamArrayByPos(__pConn, C.int(seq), C.int(num), C.int(ArgType),
+		C.int(Type), C.int(count), Valu, C.int(Buff), act))
+}
+
+/*
+ * Binding arrays of parameters for batch execution,
+ * the binding method uses the form of the parameter name.
+ */
+func cgo_xgc_bindparamarraybyname(__pConn *unsafe.Pointer, Name *C.char, num int, ArgType int,
+	Type int, count int, Valu unsafe.Pointer, Buff int, act *C.int) int {
+	return int(C.XGC_BindParamArrayByName(__pConn, Name, C.int(num), C.int(ArgType),
+		C.int(Type), C.int(count), Valu, C.int(Buff), act))
+}
+
+// Execute the SQL statement once for every row of the bound parameter arrays.
+func cgo_xgc_exec_batch(__pConn *unsafe.Pointer, query *C.char, count int) int {
+	return int(C.XGC_ExecBatch(__pConn, query, C.int(count)))
+}
+
+// Drop the parameters bound on the connection.
+func cgo_xgc_reset_params(__pConn *unsafe.Pointer) int {
+	return int(C.XGC_ResetParams(__pConn))
+}
+
 /*
  * Disconnect the database session connection established
  * by'C.XGC_OpenConn_Ips'.
@@ -132,6 +168,11 @@ func cgo_xgc_get_result_set(__pConn *unsafe.Pointer, pCT *C.int, pCC *C.int,
 	return int(C.XGC_getResultRet(__pConn, pCT, pCC, pRC, pEC, pID))
 }
 
+// Get the rowid of the last row inserted on the connection.
+func cgo_xgc_get_last_insert_id(__pConn *unsafe.Pointer, pID *C.char) int {
+	return int(C.XGC_GetLastInsertId(__pConn, pID))
+}
+
 // Release result set.
 func cgo_xgc_free_rowset(__pRes *unsafe.Pointer) int {
 	return int(C.XGC_FreeRowset(__pRes))
@@ -168,6 +209,12 @@ func cgo_xgc_next_result(__pRes *unsafe.Pointer) int {
 	return int(C.XGC_NextResult(__pRes))
 }
 
+// Open the result set of a REF CURSOR, returned by a procedure or in a column.
+func cgo_xgc_fetch_refcursor_head(__pConn *unsafe.Pointer, curname *C.char,
+	__pRes *unsafe.Pointer, fields *C.int, rows *C.longlong, cached *C.int) int {
+	return int(C.XGC_FetchRefCursorHead(__pConn, curname, __pRes, fields, rows, cached))
+}
+
 func cgo_xgc_exec_with_cursor(__pConn *unsafe.Pointer, query *C.char,
 	curname *C.char, __pRes *unsafe.Pointer, fields *C.int, rows *C.longlong, effects *C.int) int {
 	return int(C.XGC_ExecwithServerCursorReader(__pConn, query, curname, __pRes, fields, rows, effects))
@@ -178,6 +225,14 @@ func cgo_xgc_get_column_type(__pRes *unsafe.Pointer, Seq int, ColuType *C.int) i
 	return int(C.XGC_getResultcolType(__pRes, C.int(Seq), ColuType))
 }
 
+// Get the source table, name, alias, data type, type modifier
+// and flags of the specified column.
+func cgo_xgc_get_column_info(__pRes *unsafe.Pointer, Seq int, Tabname *C.char,
+	Name *C.char, Alias *C.char, ColuType *C.int, modi *C.int, flag *C.int) int {
+	return int(C.XGC_getResultColInfo(__pRes, C.int(Seq), Tabname, Name, Alias,
+		ColuType, modi, flag))
+}
+
 // Get the data of the specified column.
 func cgo_xgc_get_data(__pRes *unsafe.Pointer, Seq int, tartype int,
 	pVal *C.char, Buff uint, act *C.int) int {
@@ -205,6 +260,11 @@ func cgo_xgc_put_lob_data(__pLob *unsafe.Pointer, pVal unsafe.Pointer, act int)
 	return int(C.XGC_Put_Lob_data(__pLob, pVal, C.int(act)))
 }
 
+// Set the position the next XGC_Get_Lob_data reads from.
+func cgo_xgc_lob_read_setpos(__pLob *unsafe.Pointer, pos int) int {
+	return int(C.XGC_LobRead_SetPos(__pLob, C.int(pos)))
+}
+
 // Release large object data resources.
 func cgo_xgc_lob_distroy(__pLob *unsafe.Pointer) int {
 	return int(C.XGC_Distroy_Lob(__pLob))
@@ -216,4 +276,14 @@ func cgo_xgc_exec_with_reader(__pConn *unsafe.Pointer, Sql *C.char,
 	return int(C.XGC_ExecwithDataReader(__pConn, Sql, __pRes, fieldCount, rowCount, effectCount))
 }
 
+// Execute stored procedures and functions with the parameters bound on the connection.
+func cgo_xgc_exec_procedure(__pConn *unsafe.Pointer, query *C.char) int {
+	return int(C.XGC_Execute_procesure(__pConn, query, nil))
+}
+
+// Get the data type of the return value of the stored function last executed.
+func cgo_xgc_get_fun_return_type(__pConn *unsafe.Pointer, Type *C.int) int {
+	return int(C.XGC_GetFunReturnType(__pConn, Type))
+}
+
 /* }}*/
@@ -0,0 +1,375 @@
+package drive
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config is the typed form of a data source name. A DSN is a list of
+// KEY=value items separated by semicolons, keys are case-insensitive:
+//
+//	IP=127.0.0.1;PORT=5138;DB=SYSTEM;USER=SYSDBA;PWD=SYSDBA;CHAR_SET=UTF8
+type Config struct {
+	// Addresses of the servers, given as IP or IPS. Either takes a
+	// comma-separated list, HostPolicy picks the host of each connection
+	Hosts []string
+	// PORT, 5138 by default
+	Port int
+	// DB
+	Database string
+	// USER
+	User string
+	// PWD
+	Password string
+	// CHAR_SET, one of UTF8, GBK and GB2312
+	Charset string
+	// USESSL
+	SSL bool
+	// AUTO_COMMIT=off, the sessions start with autocommit off. The
+	// zero value keeps autocommit on
+	NoAutoCommit bool
+	// FETCH_SIZE, the number of rows the server sends per round trip,
+	// 0 keeps the default of the server
+	FetchSize int
+
+	// The options below belong to the driver and are not
+	// passed to the C library
+
+	// SERVER_CURSOR, fetch result sets through a server cursor
+	ServerCursor bool
+	// CONNECT_TIMEOUT, the time allowed to establish a connection
+	ConnectTimeout time.Duration
+	// QUERY_TIMEOUT, the deadline of the calls whose context has none
+	QueryTimeout time.Duration
+	// STMT_CACHE_SIZE, the number of prepared statements each
+	// connection keeps for reuse, 0 disables the cache
+	StmtCacheSize int
+	// HOST_POLICY, the order in which the hosts are tried for a new
+	// connection: ROUND_ROBIN, RANDOM, LEAST_RECENTLY_FAILED or
+	// FAILOVER. nil is RoundRobin
+	HostPolicy HostPolicy
+	// HOST_BACKOFF, how long a host that refused a connection is
+	// tried after the others, 0 is DEFAULT_HOST_BACKOFF
+	HostBackoff time.Duration
+	// IDENTITY_LOOKUP, LastInsertId reads the identity column of the
+	// row an INSERT added. The lookup runs two more queries on the
+	// session, inside the transaction of the statement if any
+	IdentityLookup bool
+	// TIME_ZONE (or LOC, TIMEZONE) of the session: "Asia/Shanghai",
+	// "Local", "GMT+08:00" and so on. DATE and DATETIME values are read
+	// and written in it. The session takes a fixed offset, zones that
+	// observe daylight saving time are rejected. nil keeps the server
+	// setting, values are written and read as UTC
+	Loc *time.Location
+}
+
+// The keys of a DSN, in the order FormatDSN writes them
+var dsnKeys = []string{
+	"IP", "IPS", "PORT", "DB", "USER", "PWD", "CHAR_SET",
+	"USESSL", "AUTO_COMMIT", "FETCH_SIZE",
+	"SERVER_CURSOR", "CONNECT_TIMEOUT", "QUERY_TIMEOUT", "TIME_ZONE",
+	"STMT_CACHE_SIZE", "HOST_POLICY", "HOST_BACKOFF", "IDENTITY_LOOKUP",
+}
+
+// Other names of the DSN keys
+var dsnAliases = map[string]string{
+	"LOC":      "TIME_ZONE",
+	"TIMEZONE": "TIME_ZONE",
+}
+
+// NewConfig returns a Config with the default settings.
+func NewConfig() *Config {
+	return &Config{
+		Port: 5138,
+	}
+}
+
+// ParseDSN parses a data source name into a Config. Malformed items,
+// unknown or repeated keys and invalid values are reported.
+func ParseDSN(dsn string) (*Config, error) {
+
+	cfg := NewConfig()
+	seen := make(map[string]bool)
+
+	for _, item := range strings.Split(dsn, ";") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+
+		name, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid DSN item %q: missing '='", item)
+		}
+
+		key := strings.ToUpper(strings.TrimSpace(name))
+		value = strings.TrimSpace(value)
+		if alias, ok := dsnAliases[key]; ok {
+			key = alias
+		}
+
+		if seen[key] {
+			return nil, fmt.Errorf("invalid DSN: key %s is given more than once", key)
+		}
+		seen[key] = true
+
+		err := cfg.set(key, value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if seen["IP"] && seen["IPS"] {
+		return nil, errors.New("invalid DSN: IP and IPS cannot be used together")
+	}
+
+	err := cfg.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// Set the field of a DSN key
+func (self *Config) set(key string, value string) error {
+	var err error
+
+	switch key {
+	case "IP", "IPS":
+		self.Hosts = nil
+		for _, host := range strings.Split(value, ",") {
+			self.Hosts = append(self.Hosts, strings.TrimSpace(host))
+		}
+	case "PORT":
+		self.Port, err = strconv.Atoi(value)
+	case "DB":
+		self.Database = value
+	case "USER":
+		self.User = value
+	case "PWD":
+		self.Password = value
+	case "CHAR_SET":
+		self.Charset = strings.ToUpper(value)
+	case "USESSL":
+		self.SSL, err = parseBool(value)
+	case "AUTO_COMMIT":
+		var on bool
+		on, err = parseBool(value)
+		self.NoAutoCommit = !on
+	case "FETCH_SIZE":
+		self.FetchSize, err = strconv.Atoi(value)
+	case "SERVER_CURSOR":
+		self.ServerCursor, err = parseBool(value)
+	case "CONNECT_TIMEOUT":
+		self.ConnectTimeout, err = parseTimeout(value)
+	case "QUERY_TIMEOUT":
+		self.QueryTimeout, err = parseTimeout(value)
+	case "TIME_ZONE":
+		self.Loc, err = parseLocation(value)
+	case "STMT_CACHE_SIZE":
+		self.StmtCacheSize, err = strconv.Atoi(value)
+	case "HOST_POLICY":
+		policy, ok := hostPolicies[strings.ToUpper(value)]
+		if !ok {
+			err = fmt.Errorf("unknown policy %q", value)
+		}
+		self.HostPolicy = policy
+	case "HOST_BACKOFF":
+		self.HostBackoff, err = parseTimeout(value)
+	case "IDENTITY_LOOKUP":
+		self.IdentityLookup, err = parseBool(value)
+	default:
+		return fmt.Errorf("invalid DSN: unknown key %s", key)
+	}
+
+	if err != nil {
+		return fmt.Errorf("invalid DSN value for %s: %v", key, err)
+	}
+
+	return nil
+}
+
+// A timeout is a Go duration such as "5s", or a number of seconds
+func parseTimeout(value string) (time.Duration, error) {
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+
+	return time.ParseDuration(value)
+}
+
+// Check the settings before any use of the C library
+func (self *Config) validate() error {
+
+	if len(self.Hosts) == 0 {
+		return errors.New("invalid DSN: no host given in IP or IPS")
+	}
+
+	for _, host := range self.Hosts {
+		if host == "" || strings.ContainsAny(host, ",;=") {
+			return fmt.Errorf("invalid DSN: bad host %q", host)
+		}
+	}
+
+	if self.Port <= 0 || self.Port > 65535 {
+		return fmt.Errorf("invalid DSN: port %d out of range", self.Port)
+	}
+
+	switch self.Charset {
+	case "", "UTF8", "GBK", "GB2312":
+	default:
+		return fmt.Errorf("invalid DSN: unsupported CHAR_SET %s", self.Charset)
+	}
+
+	if self.FetchSize < 0 {
+		return fmt.Errorf("invalid DSN: negative FETCH_SIZE %d", self.FetchSize)
+	}
+
+	if self.StmtCacheSize < 0 {
+		return fmt.Errorf("invalid DSN: negative STMT_CACHE_SIZE %d", self.StmtCacheSize)
+	}
+
+	if self.Loc != nil {
+		if _, err := fixedZone(self.Loc); err != nil {
+			return fmt.Errorf("invalid DSN: %v", err)
+		}
+	}
+
+	if self.ConnectTimeout < 0 || self.QueryTimeout < 0 || self.HostBackoff < 0 {
+		return errors.New("invalid DSN: negative timeout")
+	}
+
+	values := map[string]string{
+		"DB":   self.Database,
+		"USER": self.User,
+		"PWD":  self.Password,
+	}
+	for key, value := range values {
+		if strings.ContainsRune(value, ';') {
+			return fmt.Errorf("invalid DSN: %s cannot contain ';'", key)
+		}
+	}
+
+	return nil
+}
+
+// FormatDSN returns the data source name of the Config.
+func (self *Config) FormatDSN() string {
+	return self.format(true)
+}
+
+/*
+ * format writes the items of the DSN in the order of dsnKeys, zero
+ * values are left out. The driver options are only written for
+ * FormatDSN, the C library does not know them.
+ */
+func (self *Config) format(driverOptions bool) string {
+
+	var items []string
+	for _, key := range dsnKeys {
+		var value string
+
+		switch key {
+		case "IP":
+			if len(self.Hosts) == 1 {
+				value = self.Hosts[0]
+			}
+		case "IPS":
+			if len(self.Hosts) > 1 {
+				value = strings.Join(self.Hosts, ",")
+			}
+		case "PORT":
+			value = strconv.Itoa(self.Port)
+		case "DB":
+			value = self.Database
+		case "USER":
+			value = self.User
+		case "PWD":
+			value = self.Password
+		case "CHAR_SET":
+			value = self.Charset
+		case "USESSL":
+			if self.SSL {
+				value = "on"
+			}
+		case "AUTO_COMMIT":
+			value = "on"
+			if self.NoAutoCommit {
+				value = "off"
+			}
+		case "FETCH_SIZE":
+			if self.FetchSize > 0 {
+				value = strconv.Itoa(self.FetchSize)
+			}
+		case "SERVER_CURSOR":
+			if driverOptions && self.ServerCursor {
+				value = "on"
+			}
+		case "CONNECT_TIMEOUT":
+			if driverOptions && self.ConnectTimeout > 0 {
+				value = self.ConnectTimeout.String()
+			}
+		case "QUERY_TIMEOUT":
+			if driverOptions && self.QueryTimeout > 0 {
+				value = self.QueryTimeout.String()
+			}
+		case "TIME_ZONE":
+			// The offset, the name of a zone built with
+			// time.FixedZone may be empty or unknown
+			if driverOptions && self.Loc != nil {
+				value = sessionTimeZone(self.Loc)
+			}
+		case "STMT_CACHE_SIZE":
+			if driverOptions && self.StmtCacheSize > 0 {
+				value = strconv.Itoa(self.StmtCacheSize)
+			}
+		case "HOST_POLICY":
+			if driverOptions && self.HostPolicy != nil {
+				value = hostPolicyName(self.HostPolicy)
+			}
+		case "HOST_BACKOFF":
+			if driverOptions && self.HostBackoff > 0 {
+				value = self.HostBackoff.String()
+			}
+		case "IDENTITY_LOOKUP":
+			if driverOptions && self.IdentityLookup {
+				value = "on"
+			}
+		}
+
+		if value != "" {
+			items = append(items, key+"="+value)
+		}
+	}
+
+	return strings.Join(items, ";")
+}
+
+// The DSN passed to the C library to connect to one of the hosts
+func (self *Config) hostDSN(host string) string {
+	single := *self
+	single.Hosts = []string{host}
+	return single.format(false)
+}
+
+// NewConnector returns a connector for sql.OpenDB that opens
+// connections with the settings of cfg.
+func NewConnector(cfg *Config) (driver.Connector, error) {
+	if cfg == nil {
+		return nil, errors.New("nil Config")
+	}
+
+	err := cfg.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	copied := *cfg
+	copied.Hosts = append([]string(nil), cfg.Hosts...)
+
+	return newConnector(&copied), nil
+}
@@ -4,12 +4,13 @@ import (
 	"C"
 	"context"
 	"database/sql/driver"
-	"strings"
+	"errors"
 	"unsafe"
 )
 
 const (
 	ERROR_BUFF_SIZE        uint = 1024
+	ERROR_CODE_BUFF_SIZE   uint = 64
 	PREPARE_NAME_BUFF_SIZE uint = 128
 	CURSOR_NAME_BUFF_SIZE  uint = 128
 	ROWID_BUFF_SIZE        uint = 256
@@ -19,6 +20,10 @@ const (
 	LOB_BUFF_SIZE   uint = 8
 	RET_NO_DATA     int  = 100
 
+	// The buffer of a truncated value grows up to this size,
+	// a value still truncated is reported as an error
+	MAX_FIELD_SIZE uint = 1 << 30
+
 	SQL_UNKNOWN   int = 0
 	SQL_SELECT    int = 4
 	SQL_CREATE    int = 5
@@ -29,41 +34,168 @@ const (
 	SQL_PARAM_INPUTOUTPUT int = 3
 	SQL_PARAM_RETURNVALUE int = 6
 
-	SQL_XG_C_CHAR int = 2
-	SQL_XG_C_CLOB int = 41
-	SQL_XG_C_BLOB int = 42
-	SQL_XG_C_NULL int = -11
+	SQL_XG_C_BOOL    int = 1
+	SQL_XG_C_CHAR    int = 2
+	SQL_XG_C_TINYINT int = 3
+	SQL_XG_C_SHORT   int = 4
+	SQL_XG_C_INTEGER int = 5
+	SQL_XG_C_BIGINT  int = 6
+	SQL_XG_C_FLOAT   int = 7
+	SQL_XG_C_DOUBLE  int = 8
+	SQL_XG_C_CLOB    int = 41
+	SQL_XG_C_BLOB    int = 42
+	SQL_XG_C_REFCUR  int = 58
+	SQL_XG_C_NULL    int = -11
 
 	BIND_PARAM_BY_NAME int = 62
 	BIND_PARAM_BY_POS  int = 63
+
+	XG_ERROR        int = -1
+	XG_INVALID_ARG  int = -3
+	XG_NET_ERROR    int = -4
+	XG_SOCKET_ERROR int = -8
+	XG_LOGIN_ERROR  int = -9
+
+	XG_TRUNCATED_DATA int = -12
+
+	XGC_ATTR_ISO_LEVEL int = 3
+	XGC_ATTR_TIMEZONE  int = 8
+
+	XGC_ISO_READONLY   int = 1
+	XGC_ISO_READCOMMIT int = 2
+	XGC_ISO_READREPEAT int = 3
+	XGC_ISO_SERIAL     int = 4
 )
 
+/*
+ * connector opens the connections of a sql.DB. Each connection goes to
+ * the first host of the DSN that accepts it, in the order chosen by the
+ * host policy; the connector tracks the hosts refusing connections.
+ */
 type connector struct {
-	dsn string
+	cfg   *Config
+	hosts *hostSet
+}
+
+func newConnector(cfg *Config) *connector {
+	// The session is set to the offset of the zone, validate
+	// has rejected the zones without a fixed one
+	if cfg.Loc != nil {
+		cfg.Loc, _ = fixedZone(cfg.Loc)
+	}
+
+	return &connector{cfg: cfg, hosts: newHostSet(cfg)}
 }
 
 // Connect implements driver.Connector interface.
 // Connect returns a connection to the database.
 func (self *connector) Connect(ctx context.Context) (driver.Conn, error) {
 
-	obj := &xugusqlConn{conn: nil}
-	connKeyValue := C.CString(self.dsn)
+	if self.cfg.ConnectTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, self.cfg.ConnectTimeout)
+		defer cancel()
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if ctx.Done() == nil {
+		return self.connect(ctx)
+	}
+
+	type connected struct {
+		conn *xugusqlConn
+		err  error
+	}
+
+	done := make(chan connected, 1)
+	go func() {
+		conn, err := self.connect(ctx)
+		done <- connected{conn: conn, err: err}
+	}()
+
+	select {
+	case res := <-done:
+		return res.conn, res.err
+	case <-ctx.Done():
+		// Close the session the C library opens after all
+		go func() {
+			if res := <-done; res.err == nil {
+				res.conn.Close()
+			}
+		}()
+		return nil, ctx.Err()
+	}
+}
+
+// Connect to the hosts in turn until one accepts the connection
+func (self *connector) connect(ctx context.Context) (*xugusqlConn, error) {
+
+	hosts := self.hosts.candidates()
+	if len(hosts) == 0 {
+		return nil, errors.New("no host to connect to")
+	}
+
+	var err error
+	for _, host := range hosts {
+		if ctx.Err() != nil {
+			break
+		}
+
+		var conn *xugusqlConn
+		conn, err = self.connectHost(host)
+		if err == nil {
+			self.hosts.report(host, true)
+			return conn, nil
+		}
+
+		// A login refused by a host is refused by the others as well
+		if IsLogin(err) {
+			return nil, err
+		}
+		self.hosts.report(host, false)
+	}
+
+	if err == nil {
+		err = ctx.Err()
+	}
+	return nil, err
+}
+
+func (self *connector) connectHost(host string) (*xugusqlConn, error) {
+
+	obj := &xugusqlConn{
+		conn:           nil,
+		serverCursor:   self.cfg.ServerCursor,
+		queryTimeout:   self.cfg.QueryTimeout,
+		loc:            self.cfg.Loc,
+		identityLookup: self.cfg.IdentityLookup,
+	}
+
+	if self.cfg.StmtCacheSize > 0 {
+		obj.stmtCache = newStmtCache(self.cfg.StmtCacheSize)
+	}
+
+	connKeyValue := C.CString(self.cfg.hostDSN(host))
 
 	defer func() {
 		cgo_c_free(unsafe.Pointer(connKeyValue))
 	}()
 
-	pos := strings.Index(strings.ToUpper(self.dsn), "IPS=")
-	if pos != -1 {
-		IPS_COUNTER++
-		re := cgo_xgc_connect_ips(connKeyValue, &obj.conn)
-		if re < 0 {
-			return nil, obj.get_error()
-		}
-	} else {
-		re := cgo_xgc_connect(connKeyValue, &obj.conn)
-		if re < 0 {
-			return nil, obj.get_error()
+	var conn unsafe.Pointer
+	re := cgo_xgc_connect(connKeyValue, &conn)
+	obj.conn = conn
+	if re < 0 {
+		return nil, obj.get_error("XGC_OpenConn", re)
+	}
+
+	if obj.loc != nil {
+		err := obj.setTimeZone(sessionTimeZone(obj.loc))
+		if err != nil {
+			cgo_xgc_disconnect(obj.handle())
+			return nil, err
 		}
 	}
 
@@ -0,0 +1,150 @@
+package drive
+
+import (
+	"C"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+	"unsafe"
+)
+
+var valuerType = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+
+/*
+ * convertValue converts an argument to one of the types assertParamType
+ * binds: the Go integer and float types, bool, string, []byte, time.Time
+ * and nil. Valuers such as sql.NullInt64 are asked for their value,
+ * pointers are dereferenced, named types are converted by their kind
+ * and the decimal types are bound as exact text.
+ */
+func convertValue(v interface{}) (driver.Value, error) {
+
+	switch srcv := v.(type) {
+	case nil, int, int8, int16, int32, int64, uint8, uint16, uint32,
+		float32, float64, bool, string, []byte, time.Time:
+		return v, nil
+	case uint:
+		return checkUint(uint64(srcv))
+	case uint64:
+		return checkUint(srcv)
+	case json.RawMessage:
+		if srcv == nil {
+			return nil, nil
+		}
+		return string(srcv), nil
+	case driver.Valuer:
+		// A nil pointer whose Value method has a value receiver is NULL
+		rv := reflect.ValueOf(v)
+		if rv.Kind() == reflect.Pointer && rv.IsNil() &&
+			rv.Type().Elem().Implements(valuerType) {
+			return nil, nil
+		}
+
+		value, err := srcv.Value()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := value.(driver.Valuer); ok {
+			return nil, fmt.Errorf("the Value method of %T returned a driver.Valuer", v)
+		}
+		return convertValue(value)
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return checkUint(rv.Uint())
+	case reflect.Float32:
+		return float32(rv.Float()), nil
+	case reflect.Float64:
+		return rv.Float(), nil
+	case reflect.Bool:
+		return rv.Bool(), nil
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return rv.Bytes(), nil
+		}
+	}
+
+	value, ok, err := decimalValue(v)
+	if ok || err != nil {
+		return value, err
+	}
+
+	if rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil, nil
+		}
+		return convertValue(rv.Elem().Interface())
+	}
+
+	return nil, fmt.Errorf("unsupported type %T, a %s", v, rv.Kind())
+}
+
+// An unsigned integer is bound as BIGINT, it must fit in an int64
+func checkUint(v uint64) (driver.Value, error) {
+	if v > math.MaxInt64 {
+		return nil, fmt.Errorf("uint64 value %d overflows BIGINT", v)
+	}
+	return int64(v), nil
+}
+
+/*
+ * assertNative binds a boolean or numeric value with its own C type.
+ * Unsigned integers take the next wider signed type.
+ */
+func (self *__Value) assertNative(dV driver.Value) {
+
+	switch srcv := dV.(type) {
+	case bool:
+		var v C.char
+		if srcv {
+			v = 1
+		}
+		*(*C.char)(self.native(SQL_XG_C_BOOL, unsafe.Sizeof(v))) = v
+	case int8:
+		*(*C.schar)(self.native(SQL_XG_C_TINYINT, 1)) = C.schar(srcv)
+	case int16:
+		*(*C.short)(self.native(SQL_XG_C_SHORT, 2)) = C.short(srcv)
+	case uint8:
+		*(*C.short)(self.native(SQL_XG_C_SHORT, 2)) = C.short(srcv)
+	case int32:
+		*(*C.int)(self.native(SQL_XG_C_INTEGER, 4)) = C.int(srcv)
+	case uint16:
+		*(*C.int)(self.native(SQL_XG_C_INTEGER, 4)) = C.int(srcv)
+	case int:
+		*(*C.longlong)(self.native(SQL_XG_C_BIGINT, 8)) = C.longlong(srcv)
+	case int64:
+		*(*C.longlong)(self.native(SQL_XG_C_BIGINT, 8)) = C.longlong(srcv)
+	case uint32:
+		*(*C.longlong)(self.native(SQL_XG_C_BIGINT, 8)) = C.longlong(srcv)
+	case float32:
+		*(*C.float)(self.native(SQL_XG_C_FLOAT, 4)) = C.float(srcv)
+	case float64:
+		*(*C.double)(self.native(SQL_XG_C_DOUBLE, 8)) = C.double(srcv)
+	}
+}
+
+// Allocate the buffer of a fixed size value, at least 4 bytes so that
+// the C library may read a BOOL as an int
+func (self *__Value) native(types int, size uintptr) unsafe.Pointer {
+	buff := size
+	if buff < 4 {
+		buff = 4
+	}
+
+	self.value = cgo_c_calloc(uint(buff))
+	self.length = C.int(size)
+	self.buff = C.int(buff)
+	self.islob = false
+	self.types = types
+
+	return unsafe.Pointer(self.value)
+}
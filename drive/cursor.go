@@ -0,0 +1,116 @@
+package drive
+
+import (
+	"C"
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+type serverCursorKey struct{}
+
+// Sequence used to give every server cursor a unique name
+var cursorSeq uint64
+
+// WithServerCursor returns a copy of ctx that makes the queries run with it
+// fetch their result set through a server cursor, chunk by chunk, instead
+// of receiving it whole. It overrides the SERVER_CURSOR option of the DSN.
+func WithServerCursor(ctx context.Context, enabled bool) context.Context {
+	return context.WithValue(ctx, serverCursorKey{}, enabled)
+}
+
+// Whether a query run with ctx streams its result set through a server cursor
+func (self *xugusqlConn) useServerCursor(ctx context.Context) bool {
+	if enabled, ok := ctx.Value(serverCursorKey{}).(bool); ok {
+		return enabled
+	}
+	return self.serverCursor
+}
+
+// Whether a statement of sql_type runs through a server cursor. One is
+// only declared over a SELECT, the result set of DML with a RETURNING
+// clause is received at once
+func cursorQuery(sql_type int, cursor bool) bool {
+	return cursor && sql_type == SQL_SELECT
+}
+
+// Allocate a new, unique server cursor name, released with cgo_c_free
+func newCursorName() *C.char {
+	return C.CString(fmt.Sprintf("XGGO_CUR_%d", atomic.AddUint64(&cursorSeq, 1)))
+}
+
+// RefCursor holds the result set of a REF CURSOR, returned in a
+// SYS_REFCURSOR output parameter or in a cursor-valued column. Pass a
+// *RefCursor as sql.Out.Dest or to Scan. Since Go 1.22 such a value can
+// also be scanned directly into a *sql.Rows. The cursor must be closed.
+//
+// A cursor-valued column is closed by the next Next or the Close of its
+// rows unless it is scanned into a *RefCursor, one scanned into a
+// *sql.Rows is only valid until then.
+type RefCursor struct {
+	driver.Rows
+}
+
+// Scan implements the sql.Scanner interface. The cursor of a column is
+// taken over, it is released by the Close of the receiver.
+func (self *RefCursor) Scan(src interface{}) error {
+	switch srcv := src.(type) {
+	case nil:
+		self.Rows = nil
+	case *xugusqlRows:
+		moved := *srcv
+		*srcv = xugusqlRows{}
+		self.Rows = &moved
+	case driver.Rows:
+		self.Rows = srcv
+	default:
+		return fmt.Errorf("cannot scan %T into a RefCursor", src)
+	}
+	return nil
+}
+
+/*
+ * openRefCursor opens the result set of the named REF CURSOR. When the
+ * rows are not all cached on the client, the remaining chunks are fetched
+ * from the server cursor as the rows are read.
+ */
+func openRefCursor(conn unsafe.Pointer, name string,
+	loc *time.Location) (*xugusqlRows, error) {
+
+	if len(name) == 0 {
+		return nil, errors.New("empty REF CURSOR name")
+	}
+
+	curname := C.CString(name)
+
+	var fieldCount, cached C.int
+	var rowCount C.longlong
+
+	// A REF CURSOR has a single result set, like prepared rows
+	rows := &xugusqlRows{
+		rows_conn: conn,
+		prepared:  true,
+		loc:       loc,
+	}
+
+	var result unsafe.Pointer
+	re := cgo_xgc_fetch_refcursor_head(&conn, curname, &result,
+		&fieldCount, &rowCount, &cached)
+	rows.result = result
+	if re < 0 {
+		cgo_c_free(unsafe.Pointer(curname))
+		return nil, xgc_error(&conn, "XGC_FetchRefCursorHead", re)
+	}
+
+	if cached != 0 {
+		cgo_c_free(unsafe.Pointer(curname))
+		return rows, nil
+	}
+
+	rows.curname = curname
+	return rows, nil
+}
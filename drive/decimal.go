@@ -0,0 +1,238 @@
+package drive
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Decimal is an exact NUMERIC value. It is kept in its decimal text
+// form, which is how it is bound and how the server returns it, so no
+// digit is lost to floating point rounding:
+//
+//	var amount drive.Decimal
+//	err := db.QueryRow("SELECT price FROM goods WHERE id = ?", id).Scan(&amount)
+//	_, err = db.Exec("UPDATE goods SET price = ? WHERE id = ?", amount, id)
+//
+// Scan a NULL into a NullDecimal.
+type Decimal struct {
+	text string
+}
+
+// NullDecimal is a Decimal that may be NULL.
+type NullDecimal struct {
+	Decimal Decimal
+	Valid   bool // Valid is true if Decimal is not NULL
+}
+
+// ParseDecimal parses a decimal number such as "-1234.5678"; an
+// exponent is accepted and expanded, "1.5e3" is 1500.
+func ParseDecimal(text string) (Decimal, error) {
+	text = strings.TrimSpace(text)
+	if !isDecimal(text) {
+		return Decimal{}, fmt.Errorf("invalid decimal %q", text)
+	}
+
+	if strings.ContainsAny(text, "eE") {
+		r, ok := new(big.Rat).SetString(text)
+		if !ok {
+			return Decimal{}, fmt.Errorf("invalid decimal %q", text)
+		}
+		return DecimalFromRat(r)
+	}
+
+	return Decimal{text: text}, nil
+}
+
+// DecimalFromRat returns the Decimal of r, which must have a finite
+// decimal expansion: 1/4 is 0.25, 1/3 is an error.
+func DecimalFromRat(r *big.Rat) (Decimal, error) {
+	scale, ok := decimalScale(r.Denom())
+	if !ok {
+		return Decimal{}, fmt.Errorf("%s has no finite decimal expansion", r.String())
+	}
+
+	return Decimal{text: r.FloatString(scale)}, nil
+}
+
+// DecimalFromFloat returns the shortest Decimal that converts back
+// to f at its precision.
+func DecimalFromFloat(f *big.Float) (Decimal, error) {
+	if f.IsInf() {
+		return Decimal{}, errors.New("infinite decimal")
+	}
+
+	return Decimal{text: f.Text('f', -1)}, nil
+}
+
+// String returns the decimal text, "0" for the zero Decimal.
+func (self Decimal) String() string {
+	if self.text == "" {
+		return "0"
+	}
+	return self.text
+}
+
+// Rat returns the value of the Decimal as a *big.Rat.
+func (self Decimal) Rat() *big.Rat {
+	r, _ := new(big.Rat).SetString(self.String())
+	return r
+}
+
+// Float64 returns the nearest float64 of the Decimal.
+func (self Decimal) Float64() float64 {
+	f, _ := strconv.ParseFloat(self.String(), 64)
+	return f
+}
+
+// Value implements driver.Valuer, the Decimal is bound as its text.
+func (self Decimal) Value() (driver.Value, error) {
+	return self.String(), nil
+}
+
+// Scan implements sql.Scanner.
+func (self *Decimal) Scan(src interface{}) error {
+	var err error
+
+	switch srcv := src.(type) {
+	case []byte:
+		*self, err = ParseDecimal(string(srcv))
+	case string:
+		*self, err = ParseDecimal(srcv)
+	case int64:
+		self.text = strconv.FormatInt(srcv, 10)
+	case float64:
+		self.text = strconv.FormatFloat(srcv, 'f', -1, 64)
+	case nil:
+		return errors.New("cannot scan NULL into a Decimal, use NullDecimal")
+	default:
+		return fmt.Errorf("cannot scan %T into a Decimal", src)
+	}
+
+	return err
+}
+
+// Value implements driver.Valuer.
+func (self NullDecimal) Value() (driver.Value, error) {
+	if !self.Valid {
+		return nil, nil
+	}
+	return self.Decimal.Value()
+}
+
+// Scan implements sql.Scanner.
+func (self *NullDecimal) Scan(src interface{}) error {
+	if src == nil {
+		self.Decimal, self.Valid = Decimal{}, false
+		return nil
+	}
+
+	self.Valid = true
+	return self.Decimal.Scan(src)
+}
+
+// A sign, digits with at most one decimal point and an optional exponent
+func isDecimal(text string) bool {
+	mantissa, exponent, found := strings.Cut(strings.ToLower(text), "e")
+	if found {
+		exponent = strings.TrimLeft(exponent, "+-")
+		if exponent == "" || strings.Trim(exponent, "0123456789") != "" {
+			return false
+		}
+	}
+
+	mantissa = strings.TrimLeft(mantissa, "+-")
+	if len(text)-len(strings.TrimLeft(text, "+-")) > 1 {
+		return false
+	}
+
+	integer, fraction, _ := strings.Cut(mantissa, ".")
+	if integer == "" && fraction == "" {
+		return false
+	}
+
+	return strings.Trim(integer, "0123456789") == "" &&
+		strings.Trim(fraction, "0123456789") == ""
+}
+
+// The number of decimals of 1/denom, when it has only the factors 2 and 5
+func decimalScale(denom *big.Int) (int, bool) {
+	d := new(big.Int).Set(denom)
+	var twos, fives int
+
+	two, five := big.NewInt(2), big.NewInt(5)
+	mod := new(big.Int)
+	for {
+		q, m := new(big.Int).QuoRem(d, two, mod)
+		if m.Sign() != 0 {
+			break
+		}
+		d, twos = q, twos+1
+	}
+	for {
+		q, m := new(big.Int).QuoRem(d, five, mod)
+		if m.Sign() != 0 {
+			break
+		}
+		d, fives = q, fives+1
+	}
+
+	if d.Cmp(big.NewInt(1)) != 0 {
+		return 0, false
+	}
+
+	if twos > fives {
+		return twos, true
+	}
+	return fives, true
+}
+
+/*
+ * decimalValue converts the decimal types database/sql does not know,
+ * *big.Rat, *big.Float and the fmt.Stringer whose text is a number, to
+ * the text bound for them. ok is false for any other value.
+ */
+func decimalValue(v interface{}) (value driver.Value, ok bool, err error) {
+
+	var d Decimal
+
+	switch srcv := v.(type) {
+	case *big.Rat:
+		if srcv == nil {
+			return nil, true, nil
+		}
+		d, err = DecimalFromRat(srcv)
+	case *big.Float:
+		if srcv == nil {
+			return nil, true, nil
+		}
+		d, err = DecimalFromFloat(srcv)
+	case *big.Int:
+		if srcv == nil {
+			return nil, true, nil
+		}
+		d = Decimal{text: srcv.String()}
+	case fmt.Stringer:
+		if rv := reflect.ValueOf(srcv); rv.Kind() == reflect.Pointer && rv.IsNil() {
+			return nil, false, nil
+		}
+
+		text := srcv.String()
+		if !isDecimal(strings.TrimSpace(text)) {
+			return nil, false, nil
+		}
+		d, err = ParseDecimal(text)
+	default:
+		return nil, false, nil
+	}
+
+	if err != nil {
+		return nil, true, err
+	}
+
+	return d.String(), true, nil
+}
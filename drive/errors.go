@@ -0,0 +1,91 @@
+package drive
+
+import (
+	"C"
+	"errors"
+	"fmt"
+	"strings"
+	"unsafe"
+)
+
+// Error is returned for the failures reported by the database server
+// or the C interface. Use errors.As to inspect it.
+type Error struct {
+	// Error code reported by the server, such as "E13001"
+	Code string
+
+	// Return code of the failing call, XG_NET_ERROR,
+	// XG_SOCKET_ERROR, XG_LOGIN_ERROR and so on
+	ReturnCode int
+
+	// Return code the C library recorded for the error of the
+	// session. It may be more precise than ReturnCode: a lost socket
+	// can fail the call with XG_ERROR and be recorded as XG_SOCKET_ERROR
+	SessionCode int
+
+	// Error message reported by the server
+	Message string
+
+	// The failing operation, the name of the XGC_* call
+	Op string
+}
+
+func (self *Error) Error() string {
+	if self.Message == "" {
+		return fmt.Sprintf("%s: error %d", self.Op, self.ReturnCode)
+	}
+	if self.Code != "" && !strings.Contains(self.Message, self.Code) {
+		return fmt.Sprintf("%s: [%s] %s", self.Op, self.Code, self.Message)
+	}
+	return fmt.Sprintf("%s: %s", self.Op, self.Message)
+}
+
+/* Collect error information of the session from the database server */
+func xgc_error(conn *unsafe.Pointer, op string, re int) error {
+	code := cgo_c_calloc(ERROR_CODE_BUFF_SIZE)
+	message := cgo_c_calloc(ERROR_BUFF_SIZE)
+	defer func() {
+		cgo_c_free(unsafe.Pointer(code))
+		cgo_c_free(unsafe.Pointer(message))
+	}()
+
+	err := &Error{Op: op, ReturnCode: re}
+
+	var ret, length C.int
+	if cgo_xgc_error_info(conn, code, &ret, message, ERROR_BUFF_SIZE, &length) >= 0 {
+		err.Code = C.GoString(code)
+		err.Message = C.GoString(message)
+		err.SessionCode = int(ret)
+		if err.ReturnCode == 0 {
+			err.ReturnCode = int(ret)
+		}
+		return err
+	}
+
+	cgo_c_memset(message, ERROR_BUFF_SIZE)
+	cgo_xgc_error(conn, message, &length)
+	err.Message = C.GoString(message)
+
+	return err
+}
+
+// Whether the call or the session reported the return code
+func (self *Error) is(code int) bool {
+	return self.ReturnCode == code || self.SessionCode == code
+}
+
+// IsNetwork reports whether err is a network or socket failure
+// (XG_NET_ERROR, XG_SOCKET_ERROR); the connection is lost.
+func IsNetwork(err error) bool {
+	var xerr *Error
+	if !errors.As(err, &xerr) {
+		return false
+	}
+	return xerr.is(XG_NET_ERROR) || xerr.is(XG_SOCKET_ERROR)
+}
+
+// IsLogin reports whether err is a failure to log in (XG_LOGIN_ERROR).
+func IsLogin(err error) bool {
+	var xerr *Error
+	return errors.As(err, &xerr) && xerr.is(XG_LOGIN_ERROR)
+}
@@ -3,6 +3,7 @@ package drive
 
 import (
 	"database/sql"
+	"math"
 	"reflect"
 	"time"
 )
@@ -13,14 +14,15 @@ type NullTime struct {
 }
 
 type xugusqlField struct {
+	// The table the column is selected from,
+	// empty for an expression
 	tableName string
 
 	/*
 	 * Store the name of the column
 	 * name of the current field
 	 * */
-	name   string
-	length int
+	name string
 
 	/*
 	 * Store the data type information
@@ -54,6 +56,7 @@ const (
 	fieldTypeLob         fieldType = 40
 	fieldTypeClob        fieldType = 41
 	fieldTypeBlob        fieldType = 42
+	fieldTypeRefCursor   fieldType = 58
 )
 
 /* {{ */
@@ -99,6 +102,8 @@ func (self *xugusqlField) typeDatabaseName() string {
 		return "CLOB"
 	case fieldTypeBlob:
 		return "BLOB"
+	case fieldTypeRefCursor:
+		return "REFCURSOR"
 	default:
 		return ""
 	}
@@ -123,20 +128,25 @@ func (self *xugusqlField) scanType() reflect.Type {
 		return scanTypeFloat64
 	case fieldTypeDate,
 		fieldTypeTime,
-		fieldTypeDatetime:
+		fieldTypeTimeTZ,
+		fieldTypeDatetime,
+		fieldTypeDatetimeTZ:
 		return scanTypeNullTime
-	case fieldTypeTimeTZ,
-		fieldTypeDatetimeTZ,
-		fieldTypeChar,
+	case fieldTypeChar,
 		fieldTypeBinary,
 		fieldTypeInterval,
-		fieldTypeNumeric,
-		fieldTypeIntervalY2M,
-		fieldTypeIntervalD2S,
 		fieldTypeLob,
 		fieldTypeClob,
 		fieldTypeBlob:
 		return scanTypeRawBytes
+	case fieldTypeNumeric:
+		return scanTypeDecimal
+	case fieldTypeIntervalY2M:
+		return scanTypeIntervalY2M
+	case fieldTypeIntervalD2S:
+		return scanTypeIntervalD2S
+	case fieldTypeRefCursor:
+		return scanTypeRefCursor
 	default:
 		return scanTypeUnknown
 
@@ -160,4 +170,33 @@ var (
 	scanTypeUint32    = reflect.TypeOf(uint32(0))
 	scanTypeUint64    = reflect.TypeOf(uint64(0))
 	scanTypeBool      = reflect.TypeOf(bool(false))
+	scanTypeRefCursor = reflect.TypeOf(RefCursor{})
+	scanTypeDecimal   = reflect.TypeOf(Decimal{})
+
+	scanTypeIntervalY2M = reflect.TypeOf(IntervalYearMonth(0))
+	scanTypeIntervalD2S = reflect.TypeOf(IntervalDaySecond(0))
 )
+
+// The precision and scale of a NUMERIC column are not reported,
+// xugusql.h does not document how col_modi encodes them
+func (self *xugusqlField) precisionScale() (int64, int64, bool) {
+	return 0, 0, false
+}
+
+// The length of a variable length column, unlimited for the large
+// objects. That of CHAR, VARCHAR and BINARY is not reported, xugusql.h
+// does not document col_modi
+func (self *xugusqlField) typeLength() (int64, bool) {
+	switch self.fieldType {
+	case fieldTypeLob, fieldTypeClob, fieldTypeBlob:
+		return math.MaxInt64, true
+	}
+
+	return 0, false
+}
+
+// Whether the column accepts NULL is not reported, xugusql.h does not
+// document the bits of col_flag
+func (self *xugusqlField) nullable() (bool, bool) {
+	return false, false
+}
@@ -0,0 +1,193 @@
+package drive
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DEFAULT_HOST_BACKOFF is how long a host that refused a connection is
+// tried after the other hosts when HOST_BACKOFF is not given
+const DEFAULT_HOST_BACKOFF time.Duration = 30 * time.Second
+
+// HostStatus is the health of a host of the DSN, as tracked by the
+// connector opening the connections.
+type HostStatus struct {
+	Host string
+	// The number of connections refused in a row, 0 once one succeeds
+	Failures int
+	// The time of the last refused connection, zero if there was none
+	LastFailure time.Time
+}
+
+/*
+ * HostPolicy chooses the host of each new connection. Order returns the
+ * indexes of hosts in the order they are tried; seq counts the
+ * connections opened by the connector. The hosts that refused a
+ * connection within HOST_BACKOFF are moved after the others whatever
+ * the policy, and are still tried when no other host is left.
+ */
+type HostPolicy interface {
+	Order(seq uint64, hosts []HostStatus) []int
+}
+
+// The host selection policies of HOST_POLICY
+var (
+	// RoundRobin starts each connection on the host after the one
+	// the previous connection started on. It is the default.
+	RoundRobin HostPolicy = roundRobin{}
+	// Random starts each connection on a host picked at random.
+	Random HostPolicy = random{}
+	// LeastRecentlyFailed prefers the hosts whose last refused
+	// connection is the oldest, the hosts that never refused one
+	// take turns first.
+	LeastRecentlyFailed HostPolicy = leastRecentlyFailed{}
+	// PrimaryFailover always starts on the first host, the
+	// following ones are only used when it is down.
+	PrimaryFailover HostPolicy = primaryFailover{}
+)
+
+// The names of the policies in a DSN
+var hostPolicies = map[string]HostPolicy{
+	"ROUND_ROBIN":           RoundRobin,
+	"RANDOM":                Random,
+	"LEAST_RECENTLY_FAILED": LeastRecentlyFailed,
+	"FAILOVER":              PrimaryFailover,
+}
+
+// The DSN name of a policy, empty for a policy of the application
+func hostPolicyName(policy HostPolicy) string {
+	for name, known := range hostPolicies {
+		if known == policy {
+			return name
+		}
+	}
+	return ""
+}
+
+type roundRobin struct{}
+
+func (roundRobin) Order(seq uint64, hosts []HostStatus) []int {
+	return rotate(len(hosts), int(seq%uint64(len(hosts))))
+}
+
+type random struct{}
+
+func (random) Order(seq uint64, hosts []HostStatus) []int {
+	return rotate(len(hosts), rand.Intn(len(hosts)))
+}
+
+type leastRecentlyFailed struct{}
+
+func (leastRecentlyFailed) Order(seq uint64, hosts []HostStatus) []int {
+	order := rotate(len(hosts), int(seq%uint64(len(hosts))))
+	sort.SliceStable(order, func(i, j int) bool {
+		return hosts[order[i]].LastFailure.Before(hosts[order[j]].LastFailure)
+	})
+	return order
+}
+
+type primaryFailover struct{}
+
+func (primaryFailover) Order(seq uint64, hosts []HostStatus) []int {
+	return rotate(len(hosts), 0)
+}
+
+// The indexes 0..n-1 starting at first
+func rotate(n int, first int) []int {
+	order := make([]int, n)
+	for pos := range order {
+		order[pos] = (first + pos) % n
+	}
+	return order
+}
+
+/*
+ * hostSet is the state the connector keeps on the hosts of its DSN,
+ * shared by the goroutines opening connections through it.
+ */
+type hostSet struct {
+	policy  HostPolicy
+	backoff time.Duration
+
+	mu    sync.Mutex
+	seq   uint64
+	hosts []HostStatus
+}
+
+func newHostSet(cfg *Config) *hostSet {
+	set := &hostSet{
+		policy:  cfg.HostPolicy,
+		backoff: cfg.HostBackoff,
+	}
+
+	if set.policy == nil {
+		set.policy = RoundRobin
+	}
+	if set.backoff == 0 {
+		set.backoff = DEFAULT_HOST_BACKOFF
+	}
+
+	for _, host := range cfg.Hosts {
+		set.hosts = append(set.hosts, HostStatus{Host: host})
+	}
+
+	return set
+}
+
+// The hosts to try for a new connection, in order
+func (self *hostSet) candidates() []string {
+	self.mu.Lock()
+	seq := self.seq
+	self.seq++
+	hosts := append([]HostStatus(nil), self.hosts...)
+	self.mu.Unlock()
+
+	// Each host is tried once, whatever the policy returns
+	var order []int
+	seen := make([]bool, len(hosts))
+	for _, index := range self.policy.Order(seq, hosts) {
+		if index >= 0 && index < len(hosts) && !seen[index] {
+			seen[index] = true
+			order = append(order, index)
+		}
+	}
+
+	// The hosts that just refused a connection go last
+	now := time.Now()
+	down := func(index int) bool {
+		last := hosts[index].LastFailure
+		return !last.IsZero() && now.Sub(last) < self.backoff
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return !down(order[i]) && down(order[j])
+	})
+
+	names := make([]string, len(order))
+	for pos, index := range order {
+		names[pos] = hosts[index].Host
+	}
+
+	return names
+}
+
+// Record the outcome of a connection to host
+func (self *hostSet) report(host string, ok bool) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	for pos := range self.hosts {
+		status := &self.hosts[pos]
+		if status.Host != host {
+			continue
+		}
+
+		if ok {
+			status.Failures = 0
+		} else {
+			status.Failures++
+			status.LastFailure = time.Now()
+		}
+	}
+}
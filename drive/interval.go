@@ -0,0 +1,272 @@
+package drive
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// IntervalYearMonth is a value of INTERVAL YEAR TO MONTH, a number of
+// months. Its text form is "Y-MM", "-1-06" is minus one year and a half.
+// Months have no fixed length, it has no time.Duration. Scan a NULL
+// into a NullIntervalYearMonth.
+type IntervalYearMonth int64
+
+// IntervalDaySecond is a value of INTERVAL DAY TO SECOND. Its text form
+// is "D HH:MM:SS.ffffff", it converts to and from time.Duration. Scan a
+// NULL into a NullIntervalDaySecond.
+type IntervalDaySecond time.Duration
+
+// NullIntervalYearMonth is an IntervalYearMonth that may be NULL.
+type NullIntervalYearMonth struct {
+	Interval IntervalYearMonth
+	Valid    bool // Valid is true if Interval is not NULL
+}
+
+// NullIntervalDaySecond is an IntervalDaySecond that may be NULL.
+type NullIntervalDaySecond struct {
+	Interval IntervalDaySecond
+	Valid    bool // Valid is true if Interval is not NULL
+}
+
+// NewIntervalYearMonth returns the interval of the given years and months.
+func NewIntervalYearMonth(years int, months int) IntervalYearMonth {
+	return IntervalYearMonth(int64(years)*12 + int64(months))
+}
+
+// Years returns the whole years of the interval.
+func (self IntervalYearMonth) Years() int64 {
+	return int64(self) / 12
+}
+
+// Months returns the months left over the whole years.
+func (self IntervalYearMonth) Months() int64 {
+	return int64(self) % 12
+}
+
+func (self IntervalYearMonth) String() string {
+	months := int64(self)
+	sign := ""
+	if months < 0 {
+		sign = "-"
+		months = -months
+	}
+
+	return fmt.Sprintf("%s%d-%02d", sign, months/12, months%12)
+}
+
+// Value implements driver.Valuer, the interval is bound as its text.
+func (self IntervalYearMonth) Value() (driver.Value, error) {
+	return self.String(), nil
+}
+
+// Scan implements sql.Scanner.
+func (self *IntervalYearMonth) Scan(src interface{}) error {
+	switch srcv := src.(type) {
+	case []byte:
+		return self.parse(string(srcv))
+	case string:
+		return self.parse(srcv)
+	case int64:
+		*self = IntervalYearMonth(srcv)
+		return nil
+	case nil:
+		return errors.New("cannot scan NULL into an IntervalYearMonth, use NullIntervalYearMonth")
+	}
+
+	return fmt.Errorf("cannot scan %T into an IntervalYearMonth", src)
+}
+
+func (self *IntervalYearMonth) parse(text string) error {
+	negative, body := cutSign(strings.TrimSpace(text))
+
+	years, months, found := strings.Cut(body, "-")
+	y, err := strconv.ParseInt(years, 10, 64)
+	if err != nil || y < 0 {
+		return fmt.Errorf("invalid INTERVAL YEAR TO MONTH %q", text)
+	}
+
+	var m int64
+	if found {
+		m, err = strconv.ParseInt(months, 10, 64)
+		if err != nil || m < 0 || m >= 12 {
+			return fmt.Errorf("invalid INTERVAL YEAR TO MONTH %q", text)
+		}
+	}
+
+	if y > (math.MaxInt64-m)/12 {
+		return fmt.Errorf("INTERVAL YEAR TO MONTH %q overflows int64", text)
+	}
+
+	total := y*12 + m
+	if negative {
+		total = -total
+	}
+
+	*self = IntervalYearMonth(total)
+	return nil
+}
+
+// Duration returns the interval as a time.Duration.
+func (self IntervalDaySecond) Duration() time.Duration {
+	return time.Duration(self)
+}
+
+func (self IntervalDaySecond) String() string {
+	d := time.Duration(self)
+	sign := ""
+	if d < 0 {
+		sign = "-"
+		d = -d
+	}
+
+	days := d / (24 * time.Hour)
+	d -= days * 24 * time.Hour
+	hours := d / time.Hour
+	d -= hours * time.Hour
+	minutes := d / time.Minute
+	d -= minutes * time.Minute
+	seconds := d / time.Second
+	micros := (d - seconds*time.Second) / time.Microsecond
+
+	text := fmt.Sprintf("%s%d %02d:%02d:%02d", sign, days, hours, minutes, seconds)
+	if micros != 0 {
+		text += strings.TrimRight(fmt.Sprintf(".%06d", micros), "0")
+	}
+
+	return text
+}
+
+// Value implements driver.Valuer, the interval is bound as its text
+// with microseconds.
+func (self IntervalDaySecond) Value() (driver.Value, error) {
+	return self.String(), nil
+}
+
+// Scan implements sql.Scanner.
+func (self *IntervalDaySecond) Scan(src interface{}) error {
+	switch srcv := src.(type) {
+	case []byte:
+		return self.parse(string(srcv))
+	case string:
+		return self.parse(srcv)
+	case int64:
+		*self = IntervalDaySecond(srcv)
+		return nil
+	case nil:
+		return errors.New("cannot scan NULL into an IntervalDaySecond, use NullIntervalDaySecond")
+	}
+
+	return fmt.Errorf("cannot scan %T into an IntervalDaySecond", src)
+}
+
+// Parse "[-]D HH:MM:SS[.fffffffff]", the days or the time may be missing.
+// The hours are below 24, the minutes and seconds below 60
+func (self *IntervalDaySecond) parse(text string) error {
+	invalid := fmt.Errorf("invalid INTERVAL DAY TO SECOND %q", text)
+
+	negative, body := cutSign(strings.TrimSpace(text))
+
+	days, clock, found := strings.Cut(body, " ")
+	if !found && strings.Contains(days, ":") {
+		days, clock = "0", days
+	}
+
+	d, err := strconv.ParseInt(days, 10, 64)
+	if err != nil || d < 0 {
+		return invalid
+	}
+
+	var h, m, s, frac int64
+	if clock = strings.TrimSpace(clock); clock != "" {
+		clock, fraction, _ := strings.Cut(clock, ".")
+
+		parts := strings.Split(clock, ":")
+		if len(parts) != 3 {
+			return invalid
+		}
+		values := []*int64{&h, &m, &s}
+		limits := []int64{24, 60, 60}
+		for pos, part := range parts {
+			*values[pos], err = strconv.ParseInt(part, 10, 64)
+			if err != nil || *values[pos] < 0 || *values[pos] >= limits[pos] {
+				return invalid
+			}
+		}
+
+		if fraction != "" {
+			if len(fraction) > 9 {
+				fraction = fraction[:9]
+			}
+			frac, err = strconv.ParseInt(fraction+strings.Repeat("0", 9-len(fraction)), 10, 64)
+			if err != nil {
+				return invalid
+			}
+		}
+	}
+
+	// Below a day, the time cannot overflow
+	clockTime := time.Duration(h)*time.Hour + time.Duration(m)*time.Minute +
+		time.Duration(s)*time.Second + time.Duration(frac)
+	if d > (math.MaxInt64-int64(clockTime))/int64(24*time.Hour) {
+		return fmt.Errorf("INTERVAL DAY TO SECOND %q overflows time.Duration", text)
+	}
+
+	total := time.Duration(d)*24*time.Hour + clockTime
+	if negative {
+		total = -total
+	}
+
+	*self = IntervalDaySecond(total)
+	return nil
+}
+
+// Value implements driver.Valuer.
+func (self NullIntervalYearMonth) Value() (driver.Value, error) {
+	if !self.Valid {
+		return nil, nil
+	}
+	return self.Interval.Value()
+}
+
+// Scan implements sql.Scanner.
+func (self *NullIntervalYearMonth) Scan(src interface{}) error {
+	if src == nil {
+		self.Interval, self.Valid = 0, false
+		return nil
+	}
+
+	self.Valid = true
+	return self.Interval.Scan(src)
+}
+
+// Value implements driver.Valuer.
+func (self NullIntervalDaySecond) Value() (driver.Value, error) {
+	if !self.Valid {
+		return nil, nil
+	}
+	return self.Interval.Value()
+}
+
+// Scan implements sql.Scanner.
+func (self *NullIntervalDaySecond) Scan(src interface{}) error {
+	if src == nil {
+		self.Interval, self.Valid = 0, false
+		return nil
+	}
+
+	self.Valid = true
+	return self.Interval.Scan(src)
+}
+
+// Split the leading sign of an interval text
+func cutSign(text string) (bool, string) {
+	if strings.HasPrefix(text, "-") {
+		return true, text[1:]
+	}
+	return false, strings.TrimPrefix(text, "+")
+}
@@ -0,0 +1,94 @@
+package drive
+
+/*
+ * A placeholder of a SQL statement: '?', or ':name' with name set.
+ * The placeholder spans query[pos:end].
+ */
+type placeholder struct {
+	pos  int
+	end  int
+	name string
+}
+
+/*
+ * scanPlaceholders tokenizes the statement and returns its placeholders
+ * in order of appearance. Nothing inside a string literal ('it''s'), a
+ * quoted identifier ("a?b", `a:b`), a line comment (-- ...) or a block
+ * comment is a placeholder. A colon only starts a :name placeholder when
+ * it is followed by a letter or an underscore and does not follow an
+ * identifier character or another colon, so '::' casts are skipped.
+ * An unterminated literal or comment runs to the end of the statement.
+ */
+func scanPlaceholders(query string) []placeholder {
+	var params []placeholder
+
+	for pos := 0; pos < len(query); {
+		c := query[pos]
+
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			pos = skipQuoted(query, pos, c)
+
+		case c == '-' && pos+1 < len(query) && query[pos+1] == '-':
+			pos += 2
+			for pos < len(query) && query[pos] != '\n' {
+				pos++
+			}
+
+		case c == '/' && pos+1 < len(query) && query[pos+1] == '*':
+			pos += 2
+			for pos < len(query) && !(query[pos] == '*' && pos+1 < len(query) && query[pos+1] == '/') {
+				pos++
+			}
+			pos += 2
+
+		case c == '?':
+			params = append(params, placeholder{pos: pos, end: pos + 1})
+			pos++
+
+		case c == ':' && pos+1 < len(query) && isIdentStart(query[pos+1]) &&
+			(pos == 0 || (query[pos-1] != ':' && !isIdentChar(query[pos-1]))):
+			end := pos + 1
+			for end < len(query) && isIdentChar(query[end]) {
+				end++
+			}
+			params = append(params, placeholder{pos: pos, end: end, name: query[pos+1 : end]})
+			pos = end
+
+		case isIdentChar(c):
+			// Skip the whole word, a '?' or ':' never starts inside it
+			for pos < len(query) && isIdentChar(query[pos]) {
+				pos++
+			}
+
+		default:
+			pos++
+		}
+	}
+
+	return params
+}
+
+// Skip a literal or quoted identifier opened by quote at pos, a doubled
+// quote stands for the quote itself
+func skipQuoted(query string, pos int, quote byte) int {
+	for pos++; pos < len(query); pos++ {
+		if query[pos] != quote {
+			continue
+		}
+		if pos+1 < len(query) && query[pos+1] == quote {
+			pos++
+			continue
+		}
+		return pos + 1
+	}
+	return pos
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9')
+}
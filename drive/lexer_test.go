@@ -0,0 +1,74 @@
+package drive
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestScanPlaceholders(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []placeholder
+	}{
+		{"SELECT * FROM t WHERE a = ?", []placeholder{{pos: 26, end: 27}}},
+		{"SELECT '?' FROM t WHERE a = ?", []placeholder{{pos: 28, end: 29}}},
+		{"SELECT 'it''s ?' , ?", []placeholder{{pos: 19, end: 20}}},
+		{"SELECT a::int FROM t WHERE b = :b", []placeholder{{pos: 31, end: 33, name: "b"}}},
+		{"SELECT '12:30', :at", []placeholder{{pos: 16, end: 19, name: "at"}}},
+		{"SELECT ? -- is it ? or :x\nFROM t", []placeholder{{pos: 7, end: 8}}},
+		{"SELECT /* ? :x */ ? FROM t", []placeholder{{pos: 18, end: 19}}},
+		{`SELECT "a?b", ? FROM t`, []placeholder{{pos: 14, end: 15}}},
+		{"SELECT `a:b` FROM t", nil},
+		{"UPDATE t SET a = :a WHERE id = :id", []placeholder{
+			{pos: 17, end: 19, name: "a"},
+			{pos: 31, end: 34, name: "id"},
+		}},
+		{"SELECT * FROM t WHERE a = :name", []placeholder{{pos: 26, end: 31, name: "name"}}},
+		{"SELECT 'unterminated ?", nil},
+		{"SELECT ? /* unterminated ?", []placeholder{{pos: 7, end: 8}}},
+		{"SELECT a:", nil},
+	}
+
+	for _, test := range tests {
+		got := scanPlaceholders(test.query)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("scanPlaceholders(%q) = %v, want %v", test.query, got, test.want)
+		}
+	}
+}
+
+func FuzzScanPlaceholders(f *testing.F) {
+	seeds := []string{
+		"SELECT * FROM t WHERE a = ? AND b = ?",
+		"SELECT '?', \"a?b\", `c:d` FROM t WHERE e = :e",
+		"SELECT a::int, '12:30' FROM t -- ?\nWHERE b = :b",
+		"SELECT /* :x ? */ ? FROM t WHERE c = :name",
+		"SELECT 'it''s' FROM t WHERE d = :",
+		"/* unterminated",
+		"'unterminated",
+	}
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, query string) {
+		for _, param := range scanPlaceholders(query) {
+			if param.pos < 0 || param.pos >= param.end || param.end > len(query) {
+				t.Fatalf("placeholder %v out of range in %q", param, query)
+			}
+
+			switch query[param.pos] {
+			case '?':
+				if param.name != "" || param.end != param.pos+1 {
+					t.Fatalf("bad ? placeholder %v in %q", param, query)
+				}
+			case ':':
+				if param.name != query[param.pos+1:param.end] || param.name == "" {
+					t.Fatalf("bad :name placeholder %v in %q", param, query)
+				}
+			default:
+				t.Fatalf("placeholder %v does not start with ? or : in %q", param, query)
+			}
+		}
+	})
+}
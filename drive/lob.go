@@ -0,0 +1,211 @@
+package drive
+
+import (
+	"C"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"unsafe"
+)
+
+// LOB_CHUNK_SIZE is the size of the chunks a LobReader is read and
+// put into the large object in
+const LOB_CHUNK_SIZE int = 1 << 20
+
+type lobStreamKey struct{}
+
+// LobReader binds the content of Reader as a BLOB, or as a CLOB when
+// Clob is set. The reader is consumed chunk by chunk while binding,
+// the content is never held in a single Go slice:
+//
+//	f, _ := os.Open("report.pdf")
+//	_, err := db.Exec("INSERT INTO docs VALUES(?, ?)", id, drive.LobReader{Reader: f})
+type LobReader struct {
+	Reader io.Reader
+	Clob   bool
+}
+
+// WithLobStream returns a copy of ctx that makes the queries run with it
+// return their BLOB and CLOB columns as *Lob instead of []byte. Scan
+// such a column into a *Lob and read it as a stream:
+//
+//	rows, err := db.QueryContext(drive.WithLobStream(ctx, true), "SELECT body FROM docs")
+//	...
+//	var body drive.Lob
+//	err = rows.Scan(&body)
+//	defer body.Close()
+//	io.Copy(w, &body)
+func WithLobStream(ctx context.Context, enabled bool) context.Context {
+	return context.WithValue(ctx, lobStreamKey{}, enabled)
+}
+
+// Whether a query run with ctx returns its large objects as *Lob
+func useLobStream(ctx context.Context) bool {
+	enabled, _ := ctx.Value(lobStreamKey{}).(bool)
+	return enabled
+}
+
+/*
+ * Lob is a large object read from a result set, an io.ReadSeekCloser
+ * reading it with XGC_Get_Lob_data from the position set with
+ * XGC_LobRead_SetPos. A BLOB or CLOB column is scanned into a *Lob
+ * when the query runs with WithLobStream; any other binary or text
+ * value can be scanned into it as well and is read from memory.
+ * Close releases the large object.
+ */
+type Lob struct {
+	plob   unsafe.Pointer
+	data   []byte
+	length int64
+	pos    int64
+}
+
+// Len returns the length of the large object in bytes.
+func (self *Lob) Len() int64 {
+	return self.length
+}
+
+// Read implements io.Reader.
+func (self *Lob) Read(p []byte) (int, error) {
+	if self.pos >= self.length {
+		return 0, io.EOF
+	}
+
+	n := int64(len(p))
+	if n > self.length-self.pos {
+		n = self.length - self.pos
+	}
+	if n == 0 {
+		return 0, nil
+	}
+
+	if self.plob == nil {
+		if self.data == nil {
+			return 0, errors.New("read of a closed Lob")
+		}
+		copy(p, self.data[self.pos:self.pos+n])
+		self.pos += n
+		return int(n), nil
+	}
+
+	re := cgo_xgc_lob_read_setpos(&self.plob, int(self.pos))
+	if re < 0 {
+		return 0, &Error{Op: "XGC_LobRead_SetPos", ReturnCode: re}
+	}
+
+	re = cgo_xgc_get_lob_data(&self.plob, unsafe.Pointer(&p[0]), C.int(n))
+	if re < 0 {
+		return 0, &Error{Op: "XGC_Get_Lob_data", ReturnCode: re}
+	}
+
+	self.pos += n
+	return int(n), nil
+}
+
+// Seek implements io.Seeker.
+func (self *Lob) Seek(offset int64, whence int) (int64, error) {
+	var pos int64
+
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos = self.pos + offset
+	case io.SeekEnd:
+		pos = self.length + offset
+	default:
+		return 0, errors.New("Lob.Seek: invalid whence")
+	}
+
+	if pos < 0 {
+		return 0, errors.New("Lob.Seek: negative position")
+	}
+
+	self.pos = pos
+	return pos, nil
+}
+
+// Close releases the large object.
+func (self *Lob) Close() error {
+	self.data = nil
+	if self.plob == nil {
+		return nil
+	}
+
+	re := cgo_xgc_lob_distroy(&self.plob)
+	self.plob = nil
+	if re < 0 {
+		return &Error{Op: "XGC_Distroy_Lob", ReturnCode: re}
+	}
+
+	return nil
+}
+
+// Scan implements sql.Scanner. The large object of a *Lob value is
+// taken over, it is released by the Close of the receiver.
+func (self *Lob) Scan(src interface{}) error {
+	self.Close()
+	*self = Lob{}
+
+	switch srcv := src.(type) {
+	case *Lob:
+		*self = *srcv
+		srcv.plob, srcv.data = nil, nil
+	case []byte:
+		self.data = append([]byte{}, srcv...)
+		self.length = int64(len(srcv))
+	case string:
+		self.data = []byte(srcv)
+		self.length = int64(len(srcv))
+	case nil:
+	default:
+		return fmt.Errorf("cannot scan %T into a Lob", src)
+	}
+
+	return nil
+}
+
+/*
+ * putLob creates a large object and puts the content of reader into it
+ * in chunks of LOB_CHUNK_SIZE. An empty reader gives an empty object.
+ */
+func putLob(plob *unsafe.Pointer, reader io.Reader) error {
+
+	re := cgo_xgc_new_lob(plob)
+	if re < 0 {
+		return &Error{Op: "XGC_New_Lob", ReturnCode: re}
+	}
+
+	chunk := cgo_c_calloc(uint(LOB_CHUNK_SIZE))
+	defer cgo_c_free(unsafe.Pointer(chunk))
+
+	buff := unsafe.Slice((*byte)(unsafe.Pointer(chunk)), LOB_CHUNK_SIZE)
+	for {
+		n, err := io.ReadFull(reader, buff)
+		if n > 0 {
+			re = cgo_xgc_put_lob_data(plob, unsafe.Pointer(chunk), n)
+			if re < 0 {
+				cgo_xgc_lob_distroy(plob)
+				return &Error{Op: "XGC_Put_Lob_data", ReturnCode: re}
+			}
+		}
+
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			cgo_xgc_lob_distroy(plob)
+			return err
+		}
+	}
+
+	// Mark the end of the content
+	re = cgo_xgc_put_lob_data(plob, nil, -1)
+	if re < 0 {
+		cgo_xgc_lob_distroy(plob)
+		return &Error{Op: "XGC_Put_Lob_data", ReturnCode: re}
+	}
+
+	return nil
+}
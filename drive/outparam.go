@@ -0,0 +1,258 @@
+package drive
+
+import (
+	"C"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unsafe"
+)
+
+// ReturnValue receives the return value of a stored function. It is
+// passed, much like sql.Out, as the argument of the placeholder that
+// stands for the function result and is bound with SQL_PARAM_RETURNVALUE.
+// The value is converted according to XGC_GetFunReturnType.
+type ReturnValue struct {
+	// Dest is a pointer to the value that receives the function result
+	Dest interface{}
+}
+
+/*
+ * assertOutParam prepares the buffer of an output parameter. Output
+ * values are exchanged as text; an input/output parameter starts with
+ * the current value of its destination.
+ */
+func (self *__Value) assertOutParam(target interface{}, inout int,
+	loc *time.Location) error {
+
+	rv := reflect.ValueOf(target)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return errors.New("the destination of an output parameter must be a non-nil pointer")
+	}
+
+	var S string
+	self.length = 0
+
+	if inout == SQL_PARAM_INPUTOUTPUT {
+		iv, err := convertValue(rv.Elem().Interface())
+		if err != nil {
+			return err
+		}
+
+		if iv != nil {
+			S = valueToText(iv, loc)
+			self.length = C.int(len(S))
+		}
+	}
+
+	size := FIELD_BUFF_SIZE
+	if uint(len(S))+1 > size {
+		size = uint(len(S)) + 1
+	}
+
+	self.value = cgo_c_calloc(size)
+	copy(unsafe.Slice((*byte)(unsafe.Pointer(self.value)), size), S)
+
+	self.buff = C.int(size)
+	self.islob = false
+	self.types = SQL_XG_C_CHAR
+	self.inout = inout
+
+	// A REF CURSOR parameter returns the name of the cursor
+	switch target.(type) {
+	case *RefCursor, *driver.Rows:
+		self.types = SQL_XG_C_REFCUR
+	}
+
+	return nil
+}
+
+// Copy the values returned in the output parameters to their destinations
+func (self *parse) assignOutputs(conn *unsafe.Pointer) error {
+
+	for pos := range self.Val {
+		param := &self.Val[pos]
+		if self.targets[pos] == nil {
+			continue
+		}
+
+		var value driver.Value
+		if param.length >= 0 {
+			text := C.GoStringN(param.value, param.length)
+			value = text
+
+			if param.types == SQL_XG_C_REFCUR {
+				rows, err := openRefCursor(*conn, text, self.loc)
+				if err != nil {
+					return err
+				}
+				value = rows
+			} else if param.inout == SQL_PARAM_RETURNVALUE {
+				var rtype C.int
+				if cgo_xgc_get_fun_return_type(conn, &rtype) >= 0 {
+					value = textToValue(fieldType(rtype), text)
+				}
+			}
+		}
+
+		err := assignValue(self.targets[pos], value, self.loc)
+		if err != nil {
+			return fmt.Errorf("output parameter %d: %v", pos+1, err)
+		}
+	}
+
+	return nil
+}
+
+// The textual form in which a driver.Value is bound
+func valueToText(v driver.Value, loc *time.Location) string {
+	switch srcv := v.(type) {
+	case int64:
+		return strconv.FormatInt(srcv, 10)
+	case float64:
+		return strconv.FormatFloat(srcv, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(srcv)
+	case []byte:
+		return string(srcv)
+	case time.Time:
+		return formatTime(srcv, loc)
+	default:
+		return fmt.Sprint(srcv)
+	}
+}
+
+// Convert the text of a value of the given data type to a driver.Value
+func textToValue(ftype fieldType, text string) driver.Value {
+	switch ftype {
+	case fieldTypeTinyint, fieldTypeShort,
+		fieldTypeInteger, fieldTypeBigint:
+		if v, err := strconv.ParseInt(strings.TrimSpace(text), 10, 64); err == nil {
+			return v
+		}
+	case fieldTypeFloat, fieldTypeDouble:
+		if v, err := strconv.ParseFloat(strings.TrimSpace(text), 64); err == nil {
+			return v
+		}
+	case fieldTypeBool:
+		if v, err := parseBool(text); err == nil {
+			return v
+		}
+	}
+
+	return text
+}
+
+func parseBool(text string) (bool, error) {
+	switch strings.ToUpper(strings.TrimSpace(text)) {
+	case "T", "TRUE", "1", "Y", "YES", "ON":
+		return true, nil
+	case "F", "FALSE", "0", "N", "NO", "OFF":
+		return false, nil
+	}
+
+	return false, fmt.Errorf("cannot convert %q to bool", text)
+}
+
+/*
+ * assignValue stores a value returned by the server into the destination
+ * of an output parameter, converting text to the kind of the destination.
+ */
+func assignValue(dest interface{}, src driver.Value, loc *time.Location) error {
+
+	if scanner, ok := dest.(sql.Scanner); ok {
+		return scanner.Scan(src)
+	}
+
+	dv := reflect.ValueOf(dest)
+	if dv.Kind() != reflect.Pointer || dv.IsNil() {
+		return errors.New("destination is not a non-nil pointer")
+	}
+	dv = dv.Elem()
+
+	if src == nil {
+		switch dv.Kind() {
+		case reflect.Pointer, reflect.Interface, reflect.Slice, reflect.Map:
+			dv.Set(reflect.Zero(dv.Type()))
+			return nil
+		}
+		return fmt.Errorf("cannot store NULL into %s", dv.Type())
+	}
+
+	sv := reflect.ValueOf(src)
+	if sv.Type().AssignableTo(dv.Type()) {
+		dv.Set(sv)
+		return nil
+	}
+
+	if dv.Kind() == reflect.Pointer {
+		pv := reflect.New(dv.Type().Elem())
+		err := assignValue(pv.Interface(), src, loc)
+		if err != nil {
+			return err
+		}
+		dv.Set(pv)
+		return nil
+	}
+
+	text := valueToText(src, loc)
+	switch dv.Kind() {
+	case reflect.String:
+		dv.SetString(text)
+		return nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v, err := strconv.ParseInt(strings.TrimSpace(text), 10, dv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		dv.SetInt(v)
+		return nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v, err := strconv.ParseUint(strings.TrimSpace(text), 10, dv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		dv.SetUint(v)
+		return nil
+
+	case reflect.Float32, reflect.Float64:
+		v, err := strconv.ParseFloat(strings.TrimSpace(text), dv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		dv.SetFloat(v)
+		return nil
+
+	case reflect.Bool:
+		v, err := parseBool(text)
+		if err != nil {
+			return err
+		}
+		dv.SetBool(v)
+		return nil
+
+	case reflect.Slice:
+		if dv.Type().Elem().Kind() == reflect.Uint8 {
+			dv.SetBytes([]byte(text))
+			return nil
+		}
+	}
+
+	if _, ok := dv.Interface().(time.Time); ok {
+		tv, err := parseTime(datetimeLayout, text, loc)
+		if err != nil {
+			return err
+		}
+		dv.Set(reflect.ValueOf(tv))
+		return nil
+	}
+
+	return fmt.Errorf("unsupported destination type %s", dv.Type())
+}
@@ -3,8 +3,11 @@ package drive
 import (
 	"C"
 	"context"
+	"database/sql"
 	"database/sql/driver"
-	"errors"
+	"fmt"
+	"io"
+	"time"
 	"unsafe"
 )
 
@@ -12,31 +15,346 @@ type xugusqlConn struct {
 	conn         unsafe.Pointer
 	affectedRows int
 	insertId     int
+
+	// Boolean value, set once a cgo call on this connection has been
+	// abandoned because its context was done, or once the socket to
+	// the server has failed; the session can no longer be trusted and
+	// is reported to database/sql as bad
+	bad bool
+
+	// Boolean value, set while a transaction has turned autocommit off
+	autocommitOff bool
+
+	// Isolation level in force before the running transaction changed
+	// it, 0 when the session level is in force
+	savedIsolation int
+
+	// Closed when the abandoned cgo call has returned and its
+	// resources have been released
+	abandoned chan struct{}
+
+	// Closed once Close has disconnected the session, when it waits
+	// for an abandoned call
+	closed chan struct{}
+
+	// Boolean value, queries fetch their result set through a
+	// server cursor unless the context says otherwise
+	serverCursor bool
+
+	// Deadline given to the calls whose context has none
+	queryTimeout time.Duration
+
+	// Time zone of the session, nil for UTC
+	loc *time.Location
+
+	// Prepared statements kept for reuse, nil when disabled
+	stmtCache *stmtCache
+
+	// Boolean value, LastInsertId looks up the identity value of the
+	// row an INSERT added
+	identityLookup bool
+
+	// Identity column of each table LastInsertId was resolved for,
+	// an empty name when the table has none
+	identity map[string]string
 }
 
-func (self *xugusqlConn) get_error() error {
-	message := cgo_c_calloc(ERROR_BUFF_SIZE)
-	defer func() {
-		cgo_c_free(unsafe.Pointer(message))
+// The outcome of a cgo call run under watchCancel
+type cgoResult struct {
+	value interface{}
+	err   error
+}
+
+/*
+ * watchCancel runs the cgo call in its own goroutine so that a done
+ * context can return control to the caller. The C library offers no way
+ * to interrupt a running statement, so the call is left to finish in the
+ * background, its result is released and the connection is marked bad.
+ */
+func (self *xugusqlConn) watchCancel(ctx context.Context,
+	call func() (interface{}, error)) (interface{}, error) {
+
+	if self.bad {
+		return nil, driver.ErrBadConn
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if _, ok := ctx.Deadline(); !ok && self.queryTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, self.queryTimeout)
+		defer cancel()
+	}
+
+	if ctx.Done() == nil {
+		value, err := call()
+		return value, self.checkBad(err)
+	}
+
+	done := make(chan cgoResult, 1)
+	go func() {
+		value, err := call()
+		done <- cgoResult{value: value, err: err}
 	}()
 
-	var length C.int
-	cgo_xgc_error(&self.conn, message, &length)
-	return errors.New(C.GoString(message))
+	select {
+	case res := <-done:
+		return res.value, self.checkBad(res.err)
+	case <-ctx.Done():
+		self.bad = true
+		self.abandoned = make(chan struct{})
+
+		go func(abandoned chan struct{}) {
+			res := <-done
+			if closer, ok := res.value.(io.Closer); ok {
+				closer.Close()
+			}
+			close(abandoned)
+		}(self.abandoned)
+
+		return nil, ctx.Err()
+	}
+}
+
+// Mark the connection bad when err shows the socket to the server is gone
+func (self *xugusqlConn) checkBad(err error) error {
+	if IsNetwork(err) {
+		self.bad = true
+	}
+	return err
+}
+
+// Report err as driver.ErrBadConn when the connection has failed, for
+// operations database/sql may safely retry on another connection
+func (self *xugusqlConn) retryable(err error) error {
+	if IsNetwork(err) {
+		return fmt.Errorf("%w: %w", driver.ErrBadConn, err)
+	}
+	return err
+}
+
+// IsValid implements driver.Validator, a bad connection is
+// discarded instead of being returned to the pool.
+func (self *xugusqlConn) IsValid() bool {
+	return !self.bad
+}
+
+// ResetSession implements driver.SessionResetter. It is called before
+// a pooled connection is reused: autocommit and the isolation level left
+// by an unfinished transaction are restored and leftover bound parameters
+// are dropped.
+func (self *xugusqlConn) ResetSession(ctx context.Context) error {
+	if self.bad {
+		return driver.ErrBadConn
+	}
+
+	if self.autocommitOff {
+		err := self.checkBad(self.exec("set auto_commit on;"))
+		if err != nil {
+			return self.retryable(err)
+		}
+		self.autocommitOff = false
+	}
+
+	err := self.checkBad(self.restoreIsolation())
+	if err != nil {
+		return self.retryable(err)
+	}
+
+	re := cgo_xgc_reset_params(self.handle())
+	if re < 0 {
+		return self.retryable(self.checkBad(self.get_error("XGC_ResetParams", re)))
+	}
+
+	return nil
+}
+
+// handle returns a pointer to a copy of the session handle for the cgo
+// calls. The cgo_xgc_* wrappers receive the handle as a plain pointer,
+// the cgo check then inspects the whole connection it points into, and
+// the connection holds Go pointers
+func (self *xugusqlConn) handle() *unsafe.Pointer {
+	conn := self.conn
+	return &conn
+}
+
+func (self *xugusqlConn) get_error(op string, re int) error {
+	return xgc_error(self.handle(), op, re)
 }
 
 func (self *xugusqlConn) Begin() (driver.Tx, error) {
+	if self.bad {
+		return nil, driver.ErrBadConn
+	}
+
+	tx, err := self.begin(0)
+	return tx, self.checkBad(err)
+}
+
+// BeginTx starts a transaction with the isolation level and the
+// read-only mode of opts, mapped onto XGC_ATTR_ISO_LEVEL.
+func (self *xugusqlConn) BeginTx(ctx context.Context,
+	opts driver.TxOptions) (driver.Tx, error) {
+
+	level, err := isolationLevel(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := self.watchCancel(ctx, func() (interface{}, error) {
+		return self.begin(level)
+	})
+	if err != nil {
+		return nil, self.retryable(err)
+	}
+
+	return tx.(driver.Tx), nil
+}
+
+/*
+ * begin turns autocommit off. A non-zero level is set for the duration
+ * of the transaction, the level in force before is restored at commit
+ * or rollback.
+ */
+func (self *xugusqlConn) begin(level int) (driver.Tx, error) {
+
+	if level != 0 {
+		prev, err := self.isolation()
+		if err != nil {
+			return nil, err
+		}
+
+		err = self.setIsolation(level)
+		if err != nil {
+			return nil, err
+		}
+		self.savedIsolation = prev
+	}
+
 	err := self.exec("set auto_commit off;")
 	if err != nil {
-		return nil, self.get_error()
+		self.restoreIsolation()
+		return nil, err
 	}
+
+	self.autocommitOff = true
 	return &xugusqlTx{tconn: self}, nil
 }
 
+// Restore the isolation level changed by a transaction, whether it
+// ended or was left unfinished
+func (self *xugusqlConn) restoreIsolation() error {
+	if self.savedIsolation == 0 {
+		return nil
+	}
+
+	err := self.setIsolation(self.savedIsolation)
+	if err != nil {
+		return err
+	}
+
+	self.savedIsolation = 0
+	return nil
+}
+
+// Get the isolation level of the session
+func (self *xugusqlConn) isolation() (int, error) {
+	var level, rtype, length C.int
+
+	re := cgo_xgc_get_attr(self.handle(), XGC_ATTR_ISO_LEVEL, unsafe.Pointer(&level),
+		int(unsafe.Sizeof(level)), &rtype, &length)
+	if re < 0 {
+		return 0, self.get_error("XGC_GetAttr", re)
+	}
+
+	return int(level), nil
+}
+
+// Set the isolation level of the session
+func (self *xugusqlConn) setIsolation(level int) error {
+	value := C.int(level)
+
+	re := cgo_xgc_set_attr(self.handle(), XGC_ATTR_ISO_LEVEL, unsafe.Pointer(&value),
+		int(unsafe.Sizeof(value)))
+	if re < 0 {
+		return self.get_error("XGC_SetAttr", re)
+	}
+
+	return nil
+}
+
+// Set the time zone of the session, such as "GMT+08:00"
+func (self *xugusqlConn) setTimeZone(zone string) error {
+	value := C.CString(zone)
+	defer cgo_c_free(unsafe.Pointer(value))
+
+	re := cgo_xgc_set_attr(self.handle(), XGC_ATTR_TIMEZONE, unsafe.Pointer(value), len(zone))
+	if re < 0 {
+		return self.get_error("XGC_SetAttr", re)
+	}
+
+	return nil
+}
+
+// Map the options of a transaction onto a XGC_ISO_* level, 0 keeps the session level
+func isolationLevel(opts driver.TxOptions) (int, error) {
+
+	if opts.ReadOnly {
+		if sql.IsolationLevel(opts.Isolation) != sql.LevelDefault {
+			return 0, fmt.Errorf("read-only transactions cannot also use isolation level %s",
+				sql.IsolationLevel(opts.Isolation))
+		}
+		return XGC_ISO_READONLY, nil
+	}
+
+	switch sql.IsolationLevel(opts.Isolation) {
+	case sql.LevelDefault:
+		return 0, nil
+	case sql.LevelReadCommitted:
+		return XGC_ISO_READCOMMIT, nil
+	case sql.LevelRepeatableRead:
+		return XGC_ISO_READREPEAT, nil
+	case sql.LevelSerializable:
+		return XGC_ISO_SERIAL, nil
+	}
+
+	return 0, fmt.Errorf("isolation level %s is not supported",
+		sql.IsolationLevel(opts.Isolation))
+}
+
 func (self *xugusqlConn) Close() error {
-	re := cgo_xgc_disconnect(&self.conn)
+
+	// The prepared statements go away with the session
+	free := func() {
+		if self.stmtCache == nil {
+			return
+		}
+		for _, entry := range self.stmtCache.clear() {
+			cgo_c_free(unsafe.Pointer(entry.prename))
+		}
+	}
+
+	if self.abandoned != nil {
+		// Disconnect once the abandoned call has left the session
+		// and the statement cache
+		abandoned := self.abandoned
+		self.closed = make(chan struct{})
+		go func() {
+			defer close(self.closed)
+			<-abandoned
+			cgo_xgc_disconnect(self.handle())
+			free()
+		}()
+		return nil
+	}
+
+	defer free()
+
+	re := cgo_xgc_disconnect(self.handle())
 	if re < 0 {
-		return self.get_error()
+		return self.get_error("XGC_CloseConn", re)
 	}
 	return nil
 }
@@ -47,17 +365,12 @@ func (self *xugusqlConn) Prepare(query string) (driver.Stmt, error) {
 		cgo_c_free(unsafe.Pointer(sql))
 	}()
 
-	switch cgo_xgc_sql_type(sql) {
-	case SQL_PROCEDURE:
-		return nil, errors.New("prepare does not support stored procedures")
-	case SQL_UNKNOWN:
-		return nil, errors.New("unknown SQL statement type")
-	case SQL_CREATE:
-		return nil, errors.New("prepare does not support DDL")
-	}
+	sql_type := cgo_xgc_sql_type(sql)
 
 	stmt := &xugusqlStmt{
 		stmt_conn:   self.conn,
+		owner:       self,
+		sql_type:    sql_type,
 		prepared:    false,
 		prename:     nil,
 		curopend:    false,
@@ -67,219 +380,225 @@ func (self *xugusqlConn) Prepare(query string) (driver.Stmt, error) {
 		mysql:       query,
 	}
 
+	// Stored procedures, DDL and the statements of unknown type are
+	// not prepared on the server, every execution runs them directly
+	// through XGC_Execute_procesure or XGC_Execute_no_query
+	switch sql_type {
+	case SQL_PROCEDURE, SQL_CREATE, SQL_UNKNOWN:
+		return stmt, nil
+	}
+
+	if self.stmtCache != nil {
+		if entry := self.stmtCache.take(query); entry != nil {
+			stmt.prename = entry.prename
+			stmt.cached = entry
+			stmt.prepared = true
+			return stmt, nil
+		}
+	}
+
 	if stmt.prename == nil {
 		stmt.prename = cgo_c_calloc(PREPARE_NAME_BUFF_SIZE)
 	}
 
-	re := cgo_xgc_prepare(&self.conn, sql, stmt.prename)
+	re := cgo_xgc_prepare(self.handle(), sql, stmt.prename)
 	if re < 0 {
-		return nil, self.get_error()
+		cgo_c_free(unsafe.Pointer(stmt.prename))
+		return nil, self.get_error("XGC_Prepare2", re)
 	}
 
 	stmt.prepared = true
 
+	if self.stmtCache != nil {
+		stmt.cached = self.stmtCache.add(query, stmt.prename)
+	}
+
 	return stmt, nil
 }
 
 func (self *xugusqlConn) Query(query string,
 	args []driver.Value) (driver.Rows, error) {
+	return self.query(query, valueToNamedValue(args), self.serverCursor)
+}
+
+func (self *xugusqlConn) query(query string,
+	args []driver.NamedValue, cursor bool) (driver.Rows, error) {
 	sql := C.CString(query)
 	defer func() {
 		cgo_c_free(unsafe.Pointer(sql))
 	}()
 
+	cursor = cursorQuery(cgo_xgc_sql_type(sql), cursor)
+
 	parser := &parse{
 		bind_type:   0,
 		param_count: 0,
 		position:    0,
+		loc:         self.loc,
 	}
+	defer parser.free()
 
-	if len(args) != 0 {
-		for pos, param := range args {
-			err := parser.assertParamType(param, pos)
-			if err != nil {
-				return nil, err
-			}
-		}
-
-		if len(parser.Val) != parser.assertParamCount(query) {
-			return nil, errors.New("the number of parameters does not match")
-		}
-
-		switch parser.assertBindType(query) {
-
-		case BIND_PARAM_BY_POS:
-			for pos, param := range parser.Val {
-				if !param.islob {
-					re := cgo_xgc_bindparambypos(&self.conn, pos+1, SQL_PARAM_INPUT,
-						param.types, unsafe.Pointer(param.value), param.buff, &param.length)
-					if re < 0 {
-						return nil, self.get_error()
-					}
-				} else {
-
-					re := cgo_xgc_bindparambypos(&self.conn, pos+1, SQL_PARAM_INPUT,
-						param.types, unsafe.Pointer(&param.plob), param.buff, &param.length)
-					if re < 0 {
-						return nil, self.get_error()
-					}
-				}
-
-			}
-
-		case BIND_PARAM_BY_NAME:
-			_ = parser.assertParamName(query)
-
-			for pos, param := range parser.Val {
-
-				if !param.islob {
-					re := cgo_xgc_bindparambyname(&self.conn, parser.param_names[pos], SQL_PARAM_INPUT,
-						param.types, unsafe.Pointer(param.value), param.buff, &param.rcode, &param.length)
-					if re < 0 {
-						return nil, self.get_error()
-					}
-				} else {
-
-					re := cgo_xgc_bindparambyname(&self.conn, parser.param_names[pos], SQL_PARAM_INPUT,
-						param.types, unsafe.Pointer(&param.plob), param.buff, &param.rcode, &param.length)
-					if re < 0 {
-						return nil, self.get_error()
-					}
-				}
-			}
-
-		}
+	err := parser.bindParams(self.handle(), query, args)
+	if err != nil {
+		return nil, err
 	}
 
-	defer func() {
-		for pos, param := range parser.Val {
-			if parser.bind_type == BIND_PARAM_BY_NAME {
-				cgo_c_free(unsafe.Pointer(parser.param_names[pos]))
-			}
-
-			if !param.islob {
-				cgo_c_free(unsafe.Pointer(param.value))
-			} else {
-				cgo_xgc_lob_distroy(&param.plob)
-			}
-		}
-	}()
-
 	rows := &xugusqlRows{
 		rows_conn:   self.conn,
 		result:      nil,
 		lastRowRelt: 0,
 		lastRelt:    0,
 		prepared:    false,
+		loc:         self.loc,
 	}
 
+	var result unsafe.Pointer
 	var fieldCount, effectCount C.int
 	var rowCount C.longlong
 
-	re := cgo_xgc_exec_with_reader(&self.conn, sql, &rows.result,
+	if cursor {
+		curname := newCursorName()
+		re := cgo_xgc_exec_with_cursor(self.handle(), sql, curname, &result,
+			&fieldCount, &rowCount, &effectCount)
+		rows.result = result
+		if re < 0 {
+			cgo_c_free(unsafe.Pointer(curname))
+			return nil, self.get_error("XGC_ExecwithServerCursorReader", re)
+		}
+
+		rows.curname = curname
+		return rows, nil
+	}
+
+	re := cgo_xgc_exec_with_reader(self.handle(), sql, &result,
 		&fieldCount, &rowCount, &effectCount)
+	rows.result = result
 	if re < 0 {
-		return nil, self.get_error()
+		return nil, self.get_error("XGC_ExecwithDataReader", re)
+	}
+
+	err = parser.assignOutputs(self.handle())
+	if err != nil {
+		rows.Close()
+		return nil, err
+	}
+
+	rows.affectedRows = int64(effectCount)
+	if effectCount > 0 {
+		rows.rowid = self.lastRowID()
 	}
 
 	return rows, nil
 }
 
+/*
+ * discardRows frees the result set of a query run through Exec. Its
+ * rows are discarded, the result reports how many there were, as
+ * XGC_getResultRet gives them.
+ */
+func (self *xugusqlConn) discardRows(result unsafe.Pointer) (int64, error) {
+	var pCT, pCC, pRC, pEC C.int
+	var pID = cgo_c_calloc(ROWID_BUFF_SIZE)
+	defer func() {
+		cgo_c_free(unsafe.Pointer(pID))
+	}()
+
+	re := cgo_xgc_get_result_set(&result, &pCT, &pCC, &pRC, &pEC, pID)
+	cgo_xgc_free_rowset(&result)
+	if re < 0 {
+		return 0, self.get_error("XGC_getResultRet", re)
+	}
+
+	return int64(pRC), nil
+}
+
 func (self *xugusqlConn) Exec(query string,
 	args []driver.Value) (driver.Result, error) {
+	return self.execute(query, valueToNamedValue(args))
+}
+
+func (self *xugusqlConn) execute(query string,
+	args []driver.NamedValue) (driver.Result, error) {
 	sql := C.CString(query)
-	switch cgo_xgc_sql_type(sql) {
-	case SQL_SELECT:
-		return nil, errors.New("exec does not support queries")
-	case SQL_UNKNOWN:
-		return nil, errors.New("unknown SQL statement type")
-	}
+	defer func() {
+		cgo_c_free(unsafe.Pointer(sql))
+	}()
+
+	sql_type := cgo_xgc_sql_type(sql)
 
 	parser := &parse{
 		bind_type:   0,
 		param_count: 0,
 		position:    0,
+		loc:         self.loc,
 	}
+	defer parser.free()
 
-	if len(args) != 0 {
-		for pos, param := range args {
-			err := parser.assertParamType(param, pos)
-			if err != nil {
-				return nil, err
-			}
-		}
+	err := parser.bindParams(self.handle(), query, args)
+	if err != nil {
+		return nil, err
+	}
 
-		if len(parser.Val) != parser.assertParamCount(query) {
-			return nil, errors.New("the number of parameters does not match")
+	self.affectedRows = 0
+	self.insertId = 0
+
+	switch sql_type {
+	case SQL_PROCEDURE:
+		re := cgo_xgc_exec_procedure(self.handle(), sql)
+		if re < 0 {
+			return nil, self.get_error("XGC_Execute_procesure", re)
+		}
+	case SQL_SELECT:
+		var result unsafe.Pointer
+		var fieldCount, effectCount C.int
+		var rowCount C.longlong
+
+		re := cgo_xgc_exec_with_reader(self.handle(), sql, &result,
+			&fieldCount, &rowCount, &effectCount)
+		if re < 0 {
+			return nil, self.get_error("XGC_ExecwithDataReader", re)
 		}
 
-		switch parser.assertBindType(query) {
-		case BIND_PARAM_BY_POS:
-			for pos, param := range parser.Val {
-				if !param.islob {
-					re := cgo_xgc_bindparambypos(&self.conn, pos+1, SQL_PARAM_INPUT,
-						param.types, unsafe.Pointer(param.value), param.buff, &param.length)
-					if re < 0 {
-						return nil, self.get_error()
-					}
-				} else {
-					re := cgo_xgc_bindparambypos(&self.conn, pos+1, SQL_PARAM_INPUT,
-						param.types, unsafe.Pointer(&param.plob), param.buff, &param.length)
-					if re < 0 {
-						return nil, self.get_error()
-					}
-				}
+		count, err := self.discardRows(result)
+		if err != nil {
+			return nil, err
+		}
 
-			}
+		err = parser.assignOutputs(self.handle())
+		if err != nil {
+			return nil, err
+		}
 
-		case BIND_PARAM_BY_NAME:
-			_ = parser.assertParamName(query)
-			for pos, param := range parser.Val {
-				if !param.islob {
-					re := cgo_xgc_bindparambyname(&self.conn, parser.param_names[pos], SQL_PARAM_INPUT,
-						param.types, unsafe.Pointer(param.value), param.buff, &param.rcode, &param.length)
-					if re < 0 {
-						return nil, self.get_error()
-					}
-				} else {
-
-					re := cgo_xgc_bindparambyname(&self.conn, parser.param_names[pos], SQL_PARAM_INPUT,
-						param.types, unsafe.Pointer(&param.plob), param.buff, &param.rcode, &param.length)
-					if re < 0 {
-						return nil, self.get_error()
-					}
-				}
-			}
+		return self.newResult(query, count, ""), nil
+	default:
+		err = self.exec(query)
+		if err != nil {
+			return nil, err
 		}
 	}
 
-	defer func() {
-		cgo_c_free(unsafe.Pointer(sql))
-		for pos, param := range parser.Val {
-			if parser.bind_type == BIND_PARAM_BY_NAME {
-				cgo_c_free(unsafe.Pointer(parser.param_names[pos]))
-			}
+	err = parser.assignOutputs(self.handle())
+	if err != nil {
+		return nil, err
+	}
 
-			if !param.islob {
-				cgo_c_free(unsafe.Pointer(param.value))
-			} else {
-				cgo_xgc_lob_distroy(&param.plob)
-			}
-		}
-	}()
+	var rowid string
+	if self.affectedRows > 0 {
+		rowid = self.lastRowID()
+	}
 
-	self.affectedRows = 0
-	self.insertId = 0
+	return self.newResult(query, int64(self.affectedRows), rowid), nil
+}
 
-	err := self.exec(query)
-	if err == nil {
-		return &xugusqlResult{
-			affectedRows: int64(self.affectedRows),
-			insertId:     int64(self.insertId),
-		}, nil
-	}
+// ROWID of the last row inserted on the connection, empty if unknown
+func (self *xugusqlConn) lastRowID() string {
+	pID := cgo_c_calloc(ROWID_BUFF_SIZE)
+	defer cgo_c_free(unsafe.Pointer(pID))
 
-	return nil, err
+	if cgo_xgc_get_last_insert_id(self.handle(), pID) < 0 {
+		return ""
+	}
+	return C.GoString(pID)
 }
 
 func (self *xugusqlConn) exec(query string) error {
@@ -288,9 +607,9 @@ func (self *xugusqlConn) exec(query string) error {
 		cgo_c_free(unsafe.Pointer(sql))
 	}()
 
-	self.affectedRows = cgo_xgc_execnoquery(&self.conn, sql)
+	self.affectedRows = cgo_xgc_execnoquery(self.handle(), sql)
 	if self.affectedRows < 0 {
-		return self.get_error()
+		return self.get_error("XGC_Execute_no_query", self.affectedRows)
 	}
 
 	return nil
@@ -299,16 +618,93 @@ func (self *xugusqlConn) exec(query string) error {
 func (self *xugusqlConn) ExecContext(ctx context.Context,
 	query string, args []driver.NamedValue) (driver.Result, error) {
 
-	Value, err := namedValueToValue(args)
+	result, err := self.watchCancel(ctx, func() (interface{}, error) {
+		if self.stmtCache != nil && len(args) > 0 {
+			if stmt, ok := self.prepareCached(query); ok {
+				defer stmt.close()
+				return stmt.execute(args)
+			}
+		}
+		return self.execute(query, args)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	return self.Exec(query, Value)
+	return result.(driver.Result), nil
+}
+
+func (self *xugusqlConn) QueryContext(ctx context.Context,
+	query string, args []driver.NamedValue) (driver.Rows, error) {
+
+	cursor := self.useServerCursor(ctx)
+	rows, err := self.watchCancel(ctx, func() (interface{}, error) {
+		// The rows of a server cursor belong to their statement
+		if self.stmtCache != nil && len(args) > 0 && !cursor {
+			if stmt, ok := self.prepareCached(query); ok {
+				defer stmt.close()
+				return stmt.query(args, false)
+			}
+		}
+		return self.query(query, args, cursor)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rows.(*xugusqlRows).streamLobs = useLobStream(ctx)
+	return rows.(driver.Rows), nil
+}
+
+func (self *xugusqlConn) PrepareContext(ctx context.Context,
+	query string) (driver.Stmt, error) {
+
+	stmt, err := self.watchCancel(ctx, func() (interface{}, error) {
+		return self.Prepare(query)
+	})
+	if err != nil {
+		return nil, self.retryable(err)
+	}
+
+	return stmt.(driver.Stmt), nil
+}
+
+// CheckNamedValue implements driver.NamedValueChecker. Output parameters
+// and LobReader values are passed through untouched, a nil *LobReader is
+// NULL, every other value is converted by convertValue: all the Go
+// integer and float types, pointers, Valuers such as sql.NullString,
+// json.RawMessage and the decimal types are accepted.
+func (self *xugusqlConn) CheckNamedValue(nv *driver.NamedValue) error {
+	switch v := nv.Value.(type) {
+	case sql.Out, ReturnValue, LobReader:
+		return nil
+	case *LobReader:
+		if v == nil {
+			nv.Value = nil
+		}
+		return nil
+	}
+
+	value, err := convertValue(nv.Value)
+	if err != nil {
+		return err
+	}
+
+	nv.Value = value
+	return nil
 }
 
 func (self *xugusqlConn) Ping(ctx context.Context) error {
 
+	_, err := self.watchCancel(ctx, func() (interface{}, error) {
+		return nil, self.ping()
+	})
+
+	return self.retryable(err)
+}
+
+func (self *xugusqlConn) ping() error {
+
 	sql := C.CString("SELECT COUNT(*) FROM dual;")
 	defer func() {
 		cgo_c_free(unsafe.Pointer(sql))
@@ -318,11 +714,12 @@ func (self *xugusqlConn) Ping(ctx context.Context) error {
 	var rowCount C.longlong
 	var result unsafe.Pointer
 
-	re := cgo_xgc_exec_with_reader(&self.conn, sql, &result,
+	re := cgo_xgc_exec_with_reader(self.handle(), sql, &result,
 		&fieldCount, &rowCount, &effectCount)
 	if re < 0 {
-		return self.get_error()
+		return self.get_error("XGC_ExecwithDataReader", re)
 	}
 
+	cgo_xgc_free_rowset(&result)
 	return nil
 }
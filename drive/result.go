@@ -1,14 +1,85 @@
 package drive
 
+import (
+	"database/sql/driver"
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Result is implemented by the results of the driver. database/sql hides
+// it behind sql.Result, it is reached by executing through sql.Conn.Raw:
+//
+//	conn.Raw(func(dc interface{}) error {
+//		res, err := dc.(driver.ExecerContext).ExecContext(ctx, query, args)
+//		if err == nil {
+//			rowid = res.(drive.Result).RowID()
+//		}
+//		return err
+//	})
+type Result interface {
+	driver.Result
+
+	// RowID returns the ROWID of the last row inserted by the
+	// statement, or an empty string
+	RowID() string
+}
+
+// Dictionary query returning the identity column of a table of the
+// current schema
+const identityColumnSql = `SELECT C.COL_NAME FROM USER_COLUMNS C, USER_TABLES T
+WHERE C.TABLE_ID = T.TABLE_ID AND UPPER(T.TABLE_NAME) = UPPER(?) AND C.IS_SERIAL = TRUE`
+
+// The table of an INSERT, each part of its name plain or double-quoted
+var insertTableRegexp = regexp.MustCompile(
+	`(?is)^\s*INSERT\s+INTO\s+((?:"(?:[^"]|"")*"|[^\s(".]+)(?:\s*\.\s*(?:"(?:[^"]|"")*"|[^\s(".]+))*)`)
+
+// The last part of a table name, a quoted part keeps its case
+var tableNameRegexp = regexp.MustCompile(`(?s)(?:"((?:[^"]|"")*)"|([^\s".]+))\s*$`)
+
 type xugusqlResult struct {
 
 	// Returns the number of rows affected
 	// by update, delete and other related operations
 	affectedRows int64
 
-	// Returns the GUID number of
-	// the insert operation (not supported)
+	// Returns the value of the identity column
+	// of the last row inserted
 	insertId int64
+
+	// The error met looking up insertId
+	insertErr error
+
+	// ROWID of the last row inserted
+	rowid string
+}
+
+/*
+ * newResult builds the result of a statement. With IDENTITY_LOOKUP the
+ * identity value of the row inserted by an INSERT is looked up right
+ * away, on the session that ran the statement: once the result is
+ * returned the connection goes back to the pool and may be closed or
+ * used by another goroutine.
+ */
+func (self *xugusqlConn) newResult(query string, affected int64,
+	rowid string) *xugusqlResult {
+
+	result := &xugusqlResult{
+		affectedRows: affected,
+		rowid:        rowid,
+	}
+
+	if rowid == "" || !self.identityLookup {
+		return result
+	}
+
+	if match := insertTableRegexp.FindStringSubmatch(query); match != nil {
+		result.insertId, result.insertErr = self.identityValue(match[1], rowid)
+	}
+
+	return result
 }
 
 // LastInsertId returns the integer generated by the database
@@ -16,7 +87,15 @@ type xugusqlResult struct {
 // "auto increment" column when inserting a new row. Not all
 // databases support this feature, and the syntax of such
 // statements varies.
+//
+// With IDENTITY_LOOKUP on, the value of the identity column of the
+// last inserted row is looked up through its ROWID; otherwise, or
+// when the table has no identity column, 0 is returned.
 func (self *xugusqlResult) LastInsertId() (int64, error) {
+	if self.insertErr != nil {
+		return 0, self.insertErr
+	}
+
 	return self.insertId, nil
 }
 
@@ -26,3 +105,73 @@ func (self *xugusqlResult) LastInsertId() (int64, error) {
 func (self *xugusqlResult) RowsAffected() (int64, error) {
 	return self.affectedRows, nil
 }
+
+// RowID returns the ROWID of the last row inserted by the statement.
+func (self *xugusqlResult) RowID() string {
+	return self.rowid
+}
+
+// Look up the identity column value of the row of table with the given ROWID
+func (self *xugusqlConn) identityValue(table string, rowid string) (int64, error) {
+
+	var name string
+	if match := tableNameRegexp.FindStringSubmatch(table); match != nil {
+		name = match[2]
+		if name == "" {
+			name = strings.ReplaceAll(match[1], `""`, `"`)
+		}
+	}
+
+	column, ok := self.identity[strings.ToUpper(name)]
+	if !ok {
+		text, _, err := self.queryOne(identityColumnSql, name)
+		if err != nil {
+			return 0, err
+		}
+
+		column = text
+		if self.identity == nil {
+			self.identity = make(map[string]string)
+		}
+		self.identity[strings.ToUpper(name)] = column
+	}
+
+	if column == "" {
+		return 0, nil
+	}
+
+	quoted := `"` + strings.ReplaceAll(column, `"`, `""`) + `"`
+	text, found, err := self.queryOne(
+		"SELECT "+quoted+" FROM "+table+" WHERE ROWID = ?", rowid)
+	if err != nil || !found {
+		return 0, err
+	}
+
+	return strconv.ParseInt(strings.TrimSpace(text), 10, 64)
+}
+
+// Run a query and return the text of the first column of its first row
+func (self *xugusqlConn) queryOne(query string,
+	args ...driver.Value) (string, bool, error) {
+
+	rows, err := self.query(query, valueToNamedValue(args), false)
+	if err != nil {
+		return "", false, err
+	}
+	defer rows.Close()
+
+	dest := make([]driver.Value, len(rows.Columns()))
+	if len(dest) == 0 {
+		return "", false, errors.New("the query returned no column")
+	}
+
+	err = rows.Next(dest)
+	if err == io.EOF {
+		return "", false, nil
+	}
+	if err != nil || dest[0] == nil {
+		return "", false, err
+	}
+
+	return valueToText(dest[0], self.loc), true, nil
+}
@@ -4,6 +4,7 @@ import (
 	"C"
 	"database/sql/driver"
 	"errors"
+	"fmt"
 	"io"
 	"reflect"
 	"time"
@@ -34,19 +35,71 @@ type xugusqlRows struct {
 	// Context connection handle pointer
 	rows_conn unsafe.Pointer
 	rowset    Row
+
+	// Server cursor the result set is fetched from, chunk by chunk;
+	// nil when the whole result set has been received at once
+	curname *C.char
+	// The statement owning curname, nil when the rows own it
+	stmt *xugusqlStmt
+	// The statement cursor_seq the rows were opened with
+	cursor_seq int
+
+	// Time zone of the session, nil for UTC
+	loc *time.Location
+
+	// Boolean value, large objects are returned as *Lob
+	streamLobs bool
+	// The Lob values of the current row, released unless scanned
+	lobs []*Lob
+	// The REF CURSOR values of the current row, closed unless scanned
+	// into a RefCursor
+	cursors []*xugusqlRows
+
+	// Rows changed by the DML statement returning the result set,
+	// and the ROWID of the last row it inserted
+	affectedRows int64
+	rowid        string
 }
 
-func (self *xugusqlRows) get_error() error {
+// Rows is implemented by the result sets of the driver. A DML statement
+// with a RETURNING clause run through Query also reports the rows it
+// changed; database/sql hides it behind *sql.Rows, it is reached by
+// querying through sql.Conn.Raw:
+//
+//	conn.Raw(func(dc interface{}) error {
+//		rows, err := dc.(driver.QueryerContext).QueryContext(ctx, query, args)
+//		if err == nil {
+//			affected = rows.(drive.Rows).RowsAffected()
+//			...
+//		}
+//		return err
+//	})
+type Rows interface {
+	driver.Rows
+
+	// RowsAffected returns the number of rows changed by the
+	// statement, 0 for a SELECT
+	RowsAffected() int64
+
+	// RowID returns the ROWID of the last row inserted by the
+	// statement, or an empty string
+	RowID() string
+}
 
-	conn := self.rows_conn
-	message := cgo_c_calloc(ERROR_BUFF_SIZE)
-	defer func() {
-		cgo_c_free(unsafe.Pointer(message))
-	}()
+// RowsAffected returns the number of rows changed by the statement.
+func (self *xugusqlRows) RowsAffected() int64 {
+	return self.affectedRows
+}
 
-	var length C.int
-	cgo_xgc_error(&conn, message, &length)
-	return errors.New(C.GoString(message))
+// RowID returns the ROWID of the last row inserted by the statement.
+func (self *xugusqlRows) RowID() string {
+	return self.rowid
+}
+
+func (self *xugusqlRows) get_error(op string, re int) error {
+
+	conn := self.rows_conn
+	return xgc_error(&conn, op, re)
 }
 
 /*
@@ -68,8 +121,12 @@ func (self *xugusqlRows) Columns() []string {
 	}
 
 	column_name := cgo_c_calloc(COLUMN_NAME_BUFF_SIZE)
+	column_alias := cgo_c_calloc(COLUMN_NAME_BUFF_SIZE)
+	table_name := cgo_c_calloc(COLUMN_NAME_BUFF_SIZE)
 	defer func() {
 		cgo_c_free(unsafe.Pointer(column_name))
+		cgo_c_free(unsafe.Pointer(column_alias))
+		cgo_c_free(unsafe.Pointer(table_name))
 	}()
 
 	columns := make([]string, int(FieldCount))
@@ -90,6 +147,16 @@ func (self *xugusqlRows) Columns() []string {
 			return columns
 		}
 		fields[j].fieldType = fieldType(dtype)
+
+		var modi, flag C.int
+		cgo_c_memset(table_name, COLUMN_NAME_BUFF_SIZE)
+		cgo_c_memset(column_name, COLUMN_NAME_BUFF_SIZE)
+		cgo_c_memset(column_alias, COLUMN_NAME_BUFF_SIZE)
+		re = cgo_xgc_get_column_info(&result, j+1, table_name, column_name,
+			column_alias, &dtype, &modi, &flag)
+		if re >= 0 {
+			fields[j].tableName = C.GoString(table_name)
+		}
 	}
 
 	self.rowset.columns = fields
@@ -105,10 +172,20 @@ func (self *xugusqlRows) Close() error {
 	self.rowset.columns = nil
 	self.rowset.names = nil
 
+	self.releaseLobs()
+	self.releaseCursors()
+
+	if self.curname != nil {
+		err := self.closeCursor()
+		if err != nil {
+			return err
+		}
+	}
+
 	if result != nil {
 		re := cgo_xgc_free_rowset(&result)
 		if re < 0 {
-			return self.get_error()
+			return self.get_error("XGC_FreeRowset", re)
 		}
 		self.result = nil
 	}
@@ -135,14 +212,38 @@ func (self *xugusqlRows) Next(dest []driver.Value) error {
 		return errors.New("The result set has been released")
 	}
 
+	self.releaseLobs()
+	self.releaseCursors()
+
 	result := self.result
 	self.lastRowRelt = cgo_xgc_read_next(&result)
 	if self.lastRowRelt < 0 {
-		return self.get_error()
+		return self.get_error("XGC_ReadNext", self.lastRowRelt)
 	}
 
 	if self.lastRowRelt == RET_NO_DATA {
-		return io.EOF
+		if self.curname == nil {
+			return io.EOF
+		}
+
+		more, err := self.fetchCursor()
+		if err != nil {
+			return err
+		}
+
+		if !more {
+			return io.EOF
+		}
+
+		result = self.result
+		self.lastRowRelt = cgo_xgc_read_next(&result)
+		if self.lastRowRelt < 0 {
+			return self.get_error("XGC_ReadNext", self.lastRowRelt)
+		}
+
+		if self.lastRowRelt == RET_NO_DATA {
+			return io.EOF
+		}
 	}
 
 	pVal := cgo_c_calloc(FIELD_BUFF_SIZE)
@@ -166,34 +267,71 @@ func (self *xugusqlRows) Next(dest []driver.Value) error {
 
 			re := cgo_xgc_get_lob(&result, j+1, int(coluType), &pLob, LOB_BUFF_SIZE, &length)
 			if re < 0 && re != SQL_XG_C_NULL {
-				return self.get_error()
+				cgo_xgc_lob_distroy(&pLob)
+				return self.get_error("XGC_GetData", re)
 			}
 
-			dest[j] = make([]byte, int(length)+1)
+			if re != SQL_XG_C_NULL && self.streamLobs && coluType != fieldTypeBinary {
+				// The Lob owns the large object from now on
+				lob := &Lob{plob: pLob, length: int64(length)}
+				self.lobs = append(self.lobs, lob)
+				dest[j] = lob
+				continue
+			}
 
 			if re == SQL_XG_C_NULL {
 				dest[j] = nil
 			} else {
 				data := make([]byte, int(length))
-				cgo_xgc_get_lob_data(&pLob, unsafe.Pointer(&data[0]), length)
+				if length > 0 {
+					cgo_xgc_get_lob_data(&pLob, unsafe.Pointer(&data[0]), length)
+				}
 				dest[j] = data
 			}
 
 			cgo_xgc_lob_distroy(&pLob)
 
+		case fieldTypeRefCursor:
+			cgo_c_memset(pVal, FIELD_BUFF_SIZE)
+			re := cgo_xgc_get_data(&result, j+1, int(fieldTypeChar), pVal, FIELD_BUFF_SIZE, &length)
+			if re < 0 && re != SQL_XG_C_NULL {
+				return self.get_error("XGC_GetData", re)
+			}
+
+			if re == SQL_XG_C_NULL {
+				dest[j] = nil
+			} else {
+				cursor, err := openRefCursor(self.rows_conn, C.GoString(pVal), self.loc)
+				if err != nil {
+					return err
+				}
+				self.cursors = append(self.cursors, cursor)
+				dest[j] = cursor
+			}
+
+		case fieldTypeBool, fieldTypeTinyint, fieldTypeShort,
+			fieldTypeInteger, fieldTypeBigint,
+			fieldTypeFloat, fieldTypeDouble:
+			value, err := self.nativeValue(&result, j+1, coluType, pVal)
+			if err != nil {
+				return err
+			}
+			dest[j] = value
+
 		case fieldTypeDate:
 			cgo_c_memset(pVal, FIELD_BUFF_SIZE)
 			re := cgo_xgc_get_data(&result, j+1, int(fieldTypeChar), pVal, FIELD_BUFF_SIZE, &length)
 			if re < 0 && re != SQL_XG_C_NULL {
-				return self.get_error()
+				return self.get_error("XGC_GetData", re)
 			}
 
 			if re == SQL_XG_C_NULL {
 				dest[j] = nil
 			} else {
-				//tzone, _ := time.LoadLocation("Asia/Shanghai")
-				//tv, _ := time.ParseInLocation("2006-01-02", C.GoString(pVal), tzone)
-				tv, _ := time.Parse("2006-01-02", C.GoString(pVal))
+				tv, err := parseTime(dateLayout, C.GoString(pVal), self.loc)
+				if err != nil {
+					return err
+				}
 				dest[j] = tv
 			}
 
@@ -202,15 +340,16 @@ func (self *xugusqlRows) Next(dest []driver.Value) error {
 			cgo_c_memset(pVal, FIELD_BUFF_SIZE)
 			re := cgo_xgc_get_data(&result, j+1, int(fieldTypeChar), pVal, FIELD_BUFF_SIZE, &length)
 			if re < 0 && re != SQL_XG_C_NULL {
-				return self.get_error()
+				return self.get_error("XGC_GetData", re)
 			}
 
 			if re == SQL_XG_C_NULL {
 				dest[j] = nil
 			} else {
-				//tzone, _ := time.LoadLocation("Asia/Shanghai")
-				//tv, _ := time.ParseInLocation("15:04:05", C.GoString(pVal), tzone)
-				tv, _ := time.Parse("15:04:05", C.GoString(pVal))
+				tv, err := parseTime(timeLayout, C.GoString(pVal), self.loc)
+				if err != nil {
+					return err
+				}
 				dest[j] = tv
 			}
 
@@ -220,30 +359,29 @@ func (self *xugusqlRows) Next(dest []driver.Value) error {
 			cgo_c_memset(pVal, FIELD_BUFF_SIZE)
 			re := cgo_xgc_get_data(&result, j+1, int(fieldTypeChar), pVal, FIELD_BUFF_SIZE, &length)
 			if re < 0 && re != SQL_XG_C_NULL {
-				return self.get_error()
+				return self.get_error("XGC_GetData", re)
 			}
 
 			if re == SQL_XG_C_NULL {
 				dest[j] = nil
 			} else {
-				//tzone, _ := time.LoadLocation("Asia/Shanghai")
-				//tv, _ := time.ParseInLocation("2006-01-02 15:04:05", C.GoString(pVal), tzone)
-				tv, _ := time.Parse("2006-01-02 15:04:05", C.GoString(pVal))
+				tv, err := parseTime(datetimeLayout, C.GoString(pVal), self.loc)
+				if err != nil {
+					return err
+				}
 				dest[j] = tv
 			}
 
 		default:
-			cgo_c_memset(pVal, FIELD_BUFF_SIZE)
-			re := cgo_xgc_get_data(&result, j+1, int(fieldTypeChar), pVal, FIELD_BUFF_SIZE, &length)
-			if re < 0 && re != SQL_XG_C_NULL {
-				return self.get_error()
+			data, err := self.charData(&result, j+1, pVal)
+			if err != nil {
+				return err
 			}
 
-			dest[j] = make([]byte, int(length)+1)
-			if re == SQL_XG_C_NULL {
+			if data == nil {
 				dest[j] = nil
 			} else {
-				dest[j] = []byte(C.GoString(pVal))
+				dest[j] = data
 			}
 		}
 	}
@@ -251,13 +389,132 @@ func (self *xugusqlRows) Next(dest []driver.Value) error {
 	return nil
 }
 
+/*
+ * nativeValue fetches a boolean or numeric column with its own C type,
+ * the XG_C_* codes of these types are those of the columns. Integers
+ * are returned as int64, FLOAT and DOUBLE as float64.
+ */
+func (self *xugusqlRows) nativeValue(result *unsafe.Pointer, col int,
+	coluType fieldType, pVal *C.char) (driver.Value, error) {
+
+	var length C.int
+
+	cgo_c_memset(pVal, 8)
+	re := cgo_xgc_get_data(result, col, int(coluType), pVal, FIELD_BUFF_SIZE, &length)
+	if re == SQL_XG_C_NULL {
+		return nil, nil
+	}
+	if re < 0 {
+		return nil, self.get_error("XGC_GetData", re)
+	}
+
+	data := unsafe.Pointer(pVal)
+	switch coluType {
+	case fieldTypeBool:
+		return *(*C.char)(data) != 0, nil
+	case fieldTypeTinyint:
+		return int64(*(*C.schar)(data)), nil
+	case fieldTypeShort:
+		return int64(*(*C.short)(data)), nil
+	case fieldTypeInteger:
+		return int64(*(*C.int)(data)), nil
+	case fieldTypeBigint:
+		return int64(*(*C.longlong)(data)), nil
+	case fieldTypeFloat:
+		return float64(*(*C.float)(data)), nil
+	default:
+		return float64(*(*C.double)(data)), nil
+	}
+}
+
+/*
+ * charData fetches a column as text, nil for NULL. The value is fetched
+ * into FIELD_BUFF_SIZE first; a longer value is reported as
+ * XG_TRUNCATED_DATA with its full length and fetched again into a
+ * buffer that holds it. The bytes are taken by length, so embedded
+ * NULs are kept.
+ */
+func (self *xugusqlRows) charData(result *unsafe.Pointer, col int,
+	pVal *C.char) ([]byte, error) {
+
+	buff, size := pVal, FIELD_BUFF_SIZE
+	defer func() {
+		if buff != pVal {
+			cgo_c_free(unsafe.Pointer(buff))
+		}
+	}()
+
+	grow := func(need uint) {
+		if buff != pVal {
+			cgo_c_free(unsafe.Pointer(buff))
+		}
+		buff, size = cgo_c_calloc(need), need
+	}
+
+	for {
+		var length C.int
+
+		cgo_c_memset(buff, size)
+		re := cgo_xgc_get_data(result, col, int(fieldTypeChar), buff, size, &length)
+		if re == SQL_XG_C_NULL {
+			return nil, nil
+		}
+
+		if re == XG_TRUNCATED_DATA {
+			if size >= MAX_FIELD_SIZE {
+				return nil, fmt.Errorf("column %d: value truncated at %d bytes", col, size)
+			}
+
+			need := size * 2
+			if uint(length)+1 > need {
+				need = uint(length) + 1
+			}
+			if need > MAX_FIELD_SIZE {
+				need = MAX_FIELD_SIZE
+			}
+			grow(need)
+			continue
+		}
+
+		if re < 0 {
+			return nil, self.get_error("XGC_GetData", re)
+		}
+
+		if length < 0 || uint(length) >= size {
+			return []byte(C.GoString(buff)), nil
+		}
+
+		data := C.GoBytes(unsafe.Pointer(buff), length)
+		if data == nil {
+			data = []byte{}
+		}
+		return data, nil
+	}
+}
+
+// Release the large objects of the current row no Lob took over
+func (self *xugusqlRows) releaseLobs() {
+	for _, lob := range self.lobs {
+		lob.Close()
+	}
+	self.lobs = nil
+}
+
+// Close the cursors of the current row no RefCursor took over
+func (self *xugusqlRows) releaseCursors() {
+	for _, cursor := range self.cursors {
+		cursor.Close()
+	}
+	self.cursors = nil
+}
+
 // The driver is at the end of the current result set.
 // Test to see if there is another result set after the current one.
 // Only close Rows if there is no further result sets to read.
 func (self *xugusqlRows) HasNextResultSet() bool {
 
 	result := self.result
-	if self.prepared {
+	if self.prepared || self.curname != nil {
 		return false
 	}
 
@@ -303,6 +560,76 @@ func (self *xugusqlRows) NextResultSet() error {
 	return nil
 }
 
+// Whether the server cursor of the rows is still open
+func (self *xugusqlRows) cursorOpen() bool {
+	if self.stmt != nil {
+		return self.stmt.curopend && self.stmt.cursor_seq == self.cursor_seq
+	}
+	return self.curname != nil
+}
+
+/*
+ * fetchCursor replaces the exhausted rowset with the next chunk of the
+ * server cursor and reports whether that chunk holds any row.
+ */
+func (self *xugusqlRows) fetchCursor() (bool, error) {
+
+	if !self.cursorOpen() {
+		return false, errors.New("the server cursor of the result set has been closed")
+	}
+
+	conn := self.rows_conn
+	result := self.result
+	if result != nil {
+		cgo_xgc_free_rowset(&result)
+		self.result = nil
+	}
+
+	re := cgo_xgc_fetch_with_cursor(&conn, self.curname, &result)
+	if re < 0 {
+		return false, self.get_error("XGC_FetchServerCursorRowset", re)
+	}
+
+	self.result = result
+	if re == RET_NO_DATA || result == nil {
+		return false, nil
+	}
+
+	var count C.int
+	re = cgo_xgc_get_rows_count(&result, &count)
+	if re < 0 {
+		return false, self.get_error("XGC_getResultRecordnum", re)
+	}
+
+	return count > 0, nil
+}
+
+// Close the server cursor, unless its statement has closed it already
+func (self *xugusqlRows) closeCursor() error {
+
+	open := self.cursorOpen()
+	curname := self.curname
+	self.curname = nil
+
+	if self.stmt == nil {
+		defer cgo_c_free(unsafe.Pointer(curname))
+	} else if open {
+		self.stmt.curopend = false
+	}
+
+	if !open {
+		return nil
+	}
+
+	conn := self.rows_conn
+	re := cgo_xgc_close_cursor(&conn, curname)
+	if re < 0 {
+		return self.get_error("XGC_CloseCursor", re)
+	}
+
+	return nil
+}
+
 /* {{ */
 /* {{ type ColumnTypeScanType interface }} */
 func (self *xugusqlRows) ColumnTypeScanType(index int) reflect.Type {
@@ -318,19 +645,26 @@ func (self *xugusqlRows) ColumnTypeDatabaseTypeName(index int) string {
 /* {{ */
 /* {{ RowsColumnTypeLength }} */
 func (self *xugusqlRows) ColumnTypeLength(index int) (int64, bool) {
-	return 0, false
+	return self.rowset.columns[index].typeLength()
 }
 
 /* {{ */
 /* {{ RowsColumnTypeNullable */
 func (self *xugusqlRows) ColumnTypeNullable(index int) (nullable, ok bool) {
-	/* not support */
-	return false, false
+	return self.rowset.columns[index].nullable()
 }
 
 /* {{ */
 /* {{ RowsColumnTypePrecisionScale */
 func (self *xugusqlRows) ColumnTypePrecisionScale(index int) (int64, int64, bool) {
-	/* not support */
-	return 0, 0, false
+	return self.rowset.columns[index].precisionScale()
+}
+
+/* {{ */
+/* {{ ColumnTypeTableName */
+// ColumnTypeTableName returns the table the column is selected from,
+// or an empty string for an expression. database/sql does not expose
+// it; reach it through the driver.Rows of a sql.Conn.Raw query.
+func (self *xugusqlRows) ColumnTypeTableName(index int) string {
+	return self.rowset.columns[index].tableName
 }
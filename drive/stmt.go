@@ -2,8 +2,8 @@ package drive
 
 import (
 	"C"
+	"context"
 	"database/sql/driver"
-	"errors"
 	"unsafe"
 )
 
@@ -11,6 +11,10 @@ type xugusqlStmt struct {
 
 	// Context connection handle pointer
 	stmt_conn unsafe.Pointer
+	// The connection that prepared the statement
+	owner *xugusqlConn
+	// Statement type as reported by fun_sql_type
+	sql_type int
 
 	// Boolean value, used to identify whether
 	// the executed SQL statement has been prepared
@@ -23,44 +27,88 @@ type xugusqlStmt struct {
 	curopend bool
 	// Cursor name
 	curname *C.char
+	// Incremented every time the cursor is opened, so that rows
+	// can tell whether the open cursor is still theirs
+	cursor_seq int
 	//  The number of parameters
 	// in the executed SQL statement
 	param_count int
 	mysql       string
 	// Context result set handle pointer
 	result unsafe.Pointer
+	// The entry of the statement cache prename belongs to,
+	// nil when the statement owns prename
+	cached *cachedStmt
 }
 
-/* Collect error information from the database server */
-func (self *xugusqlStmt) get_error() error {
-	message := cgo_c_calloc(ERROR_BUFF_SIZE)
-	defer func() {
-		cgo_c_free(unsafe.Pointer(message))
-	}()
+// handle returns a pointer to a copy of the session handle for the cgo
+// calls. The cgo_xgc_* wrappers receive the handle as a plain pointer,
+// the cgo check then inspects the whole statement it points into, and
+// the statement holds Go pointers
+func (self *xugusqlStmt) handle() *unsafe.Pointer {
+	conn := self.stmt_conn
+	return &conn
+}
 
-	var length C.int
-	cgo_xgc_error(&self.stmt_conn, message, &length)
-	return errors.New(C.GoString(message))
+/* Collect error information from the database server */
+func (self *xugusqlStmt) get_error(op string, re int) error {
+	return xgc_error(self.handle(), op, re)
 }
 
 /* {{ */
 func (self *xugusqlStmt) Close() error {
 
+	if self.owner != nil && self.owner.bad {
+		// The session and the statement cache belong to an abandoned
+		// call, the connection releases the cached handles when it is
+		// closed; only release the local buffers once the call returned
+		if self.cached != nil {
+			self.cached, self.prename, self.prepared = nil, nil, false
+		}
+		self.release()
+		return nil
+	}
+
+	return self.close()
+}
+
+/*
+ * close releases the statement on its session. It runs on the goroutine
+ * that owns the session, the calls watched by watchCancel close their
+ * statements with it, and never looks at the bad flag of the connection.
+ */
+func (self *xugusqlStmt) close() (err error) {
+
+	// A cached handle stays prepared, the cache owns it
+	if self.cached != nil {
+		cached := self.cached
+		self.cached, self.prename, self.prepared = nil, nil, false
+
+		defer func() {
+			uerr := self.owner.unprepare(self.owner.stmtCache.put(cached))
+			if err == nil {
+				err = uerr
+			}
+		}()
+	}
+
 	if self.curopend {
-		re := cgo_xgc_close_cursor(&self.stmt_conn, self.curname)
+		self.curopend = false
+		re := cgo_xgc_close_cursor(self.handle(), self.curname)
 		if re < 0 {
-			return self.get_error()
+			return self.get_error("XGC_CloseCursor", re)
 		}
+	}
 
+	if self.curname != nil {
 		cgo_c_free(unsafe.Pointer(self.curname))
 		self.curname = nil
-		self.curopend = false
 	}
 
 	if self.prepared {
-		re := cgo_xgc_unprepare(&self.stmt_conn, self.prename)
+		re := cgo_xgc_unprepare(self.handle(), self.prename)
 		if re < 0 {
-			return self.get_error()
+			return self.get_error("XGC_UnPrepare", re)
 		}
 
 		cgo_c_free(unsafe.Pointer(self.prename))
@@ -71,6 +119,31 @@ func (self *xugusqlStmt) Close() error {
 	return nil
 }
 
+func (self *xugusqlStmt) release() {
+	curname, prename := self.curname, self.prename
+	self.curname, self.prename = nil, nil
+	self.curopend, self.prepared = false, false
+
+	free := func() {
+		if curname != nil {
+			cgo_c_free(unsafe.Pointer(curname))
+		}
+		if prename != nil {
+			cgo_c_free(unsafe.Pointer(prename))
+		}
+	}
+
+	if abandoned := self.owner.abandoned; abandoned != nil {
+		go func() {
+			<-abandoned
+			free()
+		}()
+		return
+	}
+
+	free()
+}
+
 /* {{ */
 func (self *xugusqlStmt) NumInput() int {
 
@@ -78,6 +151,13 @@ func (self *xugusqlStmt) NumInput() int {
 		bind_type:   0,
 		param_count: 0,
 		position:    0,
+		loc:         self.owner.loc,
+	}
+
+	// The same :name placeholder may appear several times, the number
+	// of arguments is checked while binding
+	if parser.assertBindType(self.mysql) == BIND_PARAM_BY_NAME {
+		return -1
 	}
 
 	return parser.assertParamCount(self.mysql)
@@ -86,231 +166,202 @@ func (self *xugusqlStmt) NumInput() int {
 // Exec executes a prepared statement with the given arguments and
 // returns a Result summarizing the effect of the statement.
 func (self *xugusqlStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return self.execute(valueToNamedValue(args))
+}
 
-	sql := C.CString(self.mysql)
-	switch cgo_xgc_sql_type(sql) {
-	case SQL_SELECT:
-		return nil, errors.New("Exec does not support queries")
-	}
+func (self *xugusqlStmt) execute(args []driver.NamedValue) (driver.Result, error) {
 
+	// The statements Prepare leaves unprepared run directly
 	if !self.prepared {
-		return nil, errors.New("SQL statement is not Prepared")
+		return self.owner.execute(self.mysql, args)
 	}
 
 	parser := &parse{
 		bind_type:   0,
 		param_count: 0,
 		position:    0,
+		loc:         self.owner.loc,
 	}
+	defer parser.free()
 
-	if len(args) != 0 {
+	err := parser.bindParams(self.handle(), self.mysql, args)
+	if err != nil {
+		return nil, err
+	}
 
-		for pos, param := range args {
-			err := parser.assertParamType(param, pos)
-			if err != nil {
-				return nil, err
-			}
-		}
+	var result unsafe.Pointer
+	re := cgo_xgc_execute(self.handle(), self.prename, nil, &result)
+	if re < 0 {
+		return nil, self.get_error("XGC_Execute2", re)
+	}
 
-		if len(parser.Val) != parser.assertParamCount(self.mysql) {
-			return nil, errors.New("The number of parameters does not match")
+	if self.sql_type == SQL_SELECT {
+		count, err := self.owner.discardRows(result)
+		if err != nil {
+			return nil, err
 		}
 
-		switch parser.assertBindType(self.mysql) {
-		case BIND_PARAM_BY_POS:
-			for pos, param := range parser.Val {
-				if !param.islob {
-					re := cgo_xgc_bindparambypos(&self.stmt_conn, pos+1,
-						SQL_PARAM_INPUT, param.types,
-						unsafe.Pointer(param.value), param.buff, &param.length)
-					if re < 0 {
-						return nil, self.get_error()
-					}
-				} else {
-					re := cgo_xgc_bindparambypos(&self.stmt_conn, pos+1,
-						SQL_PARAM_INPUT, param.types,
-						unsafe.Pointer(&param.plob), param.buff, &param.length)
-					if re < 0 {
-						return nil, self.get_error()
-					}
-				}
-			}
-
-		case BIND_PARAM_BY_NAME:
-			parser.assertParamName(self.mysql)
-			for pos, param := range parser.Val {
-				if !param.islob {
-					re := cgo_xgc_bindparambyname(&self.stmt_conn, parser.param_names[pos],
-						SQL_PARAM_INPUT, param.types, unsafe.Pointer(param.value),
-						param.buff, &param.rcode, &param.length)
-					if re < 0 {
-						return nil, self.get_error()
-					}
-				} else {
-					re := cgo_xgc_bindparambyname(&self.stmt_conn, parser.param_names[pos],
-						SQL_PARAM_INPUT, param.types, unsafe.Pointer(&param.plob),
-						param.buff, &param.rcode, &param.length)
-					if re < 0 {
-						return nil, self.get_error()
-					}
-				}
-			}
+		err = parser.assignOutputs(self.handle())
+		if err != nil {
+			return nil, err
 		}
+
+		return self.owner.newResult(self.mysql, count, ""), nil
 	}
+	self.result = result
 
+	var pCT, pCC, pRC, pEC C.int
+	var pID = cgo_c_calloc(ROWID_BUFF_SIZE)
 	defer func() {
-		cgo_c_free(unsafe.Pointer(sql))
-		for pos, param := range parser.Val {
-			if parser.bind_type == BIND_PARAM_BY_NAME {
-				cgo_c_free(unsafe.Pointer(parser.param_names[pos]))
-			}
-
-			if !param.islob {
-				cgo_c_free(unsafe.Pointer(param.value))
-			} else {
-				cgo_xgc_lob_distroy(&param.plob)
-			}
-		}
-
+		cgo_c_free(unsafe.Pointer(pID))
 	}()
 
-	result := &xugusqlResult{
-		affectedRows: 0,
-		insertId:     0,
+	re = cgo_xgc_get_result_set(&result, &pCT, &pCC, &pRC, &pEC, pID)
+	if re < 0 {
+		return nil, self.get_error("XGC_getResultRet", re)
 	}
 
-	re := cgo_xgc_execute(&self.stmt_conn, self.prename, self.curname, &self.result)
-	if re < 0 {
-		return nil, self.get_error()
+	err = parser.assignOutputs(self.handle())
+	if err != nil {
+		return nil, err
 	}
 
-	var pCT, pCC, pRC, pEC C.int
-	var pID = cgo_c_calloc(ROWID_BUFF_SIZE)
+	return self.owner.newResult(self.mysql, int64(pEC), C.GoString(pID)), nil
+}
 
-	re = cgo_xgc_get_result_set(&self.result, &pCT, &pCC, &pRC, &pEC, pID)
-	if re < 0 {
-		return nil, self.get_error()
-	}
+// ExecContext executes a prepared statement with the given arguments,
+// giving up on the call once ctx is done.
+func (self *xugusqlStmt) ExecContext(ctx context.Context,
+	args []driver.NamedValue) (driver.Result, error) {
 
-	cgo_c_free(unsafe.Pointer(pID))
-	result.affectedRows = int64(pEC)
+	result, err := self.owner.watchCancel(ctx, func() (interface{}, error) {
+		return self.execute(args)
+	})
+	if err != nil {
+		return nil, err
+	}
 
-	return result, nil
+	return result.(driver.Result), nil
 }
 
 // QueryContext executes a prepared query statement with the given arguments
 // and returns the query results as a *Rows.
+func (self *xugusqlStmt) QueryContext(ctx context.Context,
+	args []driver.NamedValue) (driver.Rows, error) {
+
+	rows, err := self.owner.watchCancel(ctx, func() (interface{}, error) {
+		return self.query(args, self.owner.useServerCursor(ctx))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rows.(*xugusqlRows).streamLobs = useLobStream(ctx)
+	return rows.(driver.Rows), nil
+}
+
+// Query executes a prepared query statement with the given arguments
+// and returns the query results as a *Rows.
 func (self *xugusqlStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return self.query(valueToNamedValue(args), self.owner.serverCursor)
+}
 
-	sql := C.CString(self.mysql)
-	if cgo_xgc_sql_type(sql) != SQL_SELECT {
-		return nil, errors.New("The executed SQL statement is not a SELECT")
-	}
+func (self *xugusqlStmt) query(args []driver.NamedValue, cursor bool) (driver.Rows, error) {
 
+	// The statements Prepare leaves unprepared run directly
 	if !self.prepared {
-		return nil, errors.New("SQL statement is not Prepared")
+		return self.owner.query(self.mysql, args, cursor)
 	}
 
+	cursor = cursorQuery(self.sql_type, cursor)
+
 	parser := &parse{
 		bind_type:   0,
 		param_count: 0,
 		position:    0,
+		loc:         self.owner.loc,
 	}
+	defer parser.free()
 
-	if len(args) != 0 {
+	err := parser.bindParams(self.handle(), self.mysql, args)
+	if err != nil {
+		return nil, err
+	}
 
-		for pos, param := range args {
-			err := parser.assertParamType(param, pos)
-			if err != nil {
-				return nil, err
-			}
+	if !cursor {
+		var result unsafe.Pointer
+		re := cgo_xgc_execute(self.handle(), self.prename, nil, &result)
+		if re < 0 {
+			return nil, self.get_error("XGC_Execute2", re)
 		}
+		self.result = result
 
-		if len(parser.Val) != parser.assertParamCount(self.mysql) {
-			return nil, errors.New("The number of parameters does not match")
+		rows := &xugusqlRows{
+			result:    result,
+			prepared:  self.prepared,
+			rows_conn: self.stmt_conn,
+			loc:       self.owner.loc,
 		}
 
-		switch parser.assertBindType(self.mysql) {
-		case BIND_PARAM_BY_POS:
-			for pos, param := range parser.Val {
-				if !param.islob {
-					re := cgo_xgc_bindparambypos(&self.stmt_conn, pos+1,
-						SQL_PARAM_INPUT, param.types,
-						unsafe.Pointer(param.value), param.buff, &param.length)
-					if re < 0 {
-						return nil, self.get_error()
-					}
-				} else {
-
-					re := cgo_xgc_bindparambypos(&self.stmt_conn, pos+1,
-						SQL_PARAM_INPUT, param.types,
-						unsafe.Pointer(&param.plob), param.buff, &param.length)
-					if re < 0 {
-						return nil, self.get_error()
-					}
-				}
-			}
+		var pCT, pCC, pRC, pEC C.int
+		var pID = cgo_c_calloc(ROWID_BUFF_SIZE)
+		defer func() {
+			cgo_c_free(unsafe.Pointer(pID))
+		}()
 
-		case BIND_PARAM_BY_NAME:
-			parser.assertParamName(self.mysql)
-			for pos, param := range parser.Val {
-				if !param.islob {
-					re := cgo_xgc_bindparambyname(&self.stmt_conn,
-						parser.param_names[pos],
-						SQL_PARAM_INPUT, param.types, unsafe.Pointer(param.value),
-						param.buff, &param.rcode, &param.length)
-					if re < 0 {
-						return nil, self.get_error()
-					}
-				} else {
-
-					re := cgo_xgc_bindparambyname(&self.stmt_conn,
-						parser.param_names[pos],
-						SQL_PARAM_INPUT, param.types, unsafe.Pointer(&param.plob),
-						param.buff, &param.rcode, &param.length)
-					if re < 0 {
-						return nil, self.get_error()
-					}
-				}
-			}
+		re = cgo_xgc_get_result_set(&result, &pCT, &pCC, &pRC, &pEC, pID)
+		if re < 0 {
+			rows.Close()
+			return nil, self.get_error("XGC_getResultRet", re)
 		}
-	}
 
-	defer func() {
-		cgo_c_free(unsafe.Pointer(sql))
-		for pos, param := range parser.Val {
-			if parser.bind_type == BIND_PARAM_BY_NAME {
-				cgo_c_free(unsafe.Pointer(parser.param_names[pos]))
-			}
+		err = parser.assignOutputs(self.handle())
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
 
-			if !param.islob {
-				cgo_c_free(unsafe.Pointer(param.value))
-			} else {
-				cgo_xgc_lob_distroy(&param.plob)
-			}
+		rows.affectedRows = int64(pEC)
+		rows.rowid = C.GoString(pID)
+		return rows, nil
+	}
+
+	// A statement has a single server cursor, executing it again
+	// closes the cursor of the previous result set
+	if self.curopend {
+		self.curopend = false
+		re := cgo_xgc_close_cursor(self.handle(), self.curname)
+		if re < 0 {
+			return nil, self.get_error("XGC_CloseCursor", re)
 		}
+	}
 
-	}()
+	if self.curname == nil {
+		self.curname = newCursorName()
+	}
 
-	//if self.curname == nil {
-	//	self.curname = cgo_c_calloc(CURSOR_NAME_BUFF_SIZE)
-	//}
+	var result unsafe.Pointer
+	re := cgo_xgc_execute(self.handle(), self.prename, self.curname, &result)
+	if re < 0 {
+		return nil, self.get_error("XGC_Execute2", re)
+	}
 
-	re := cgo_xgc_execute(&self.stmt_conn, self.prename, self.curname, &self.result)
+	re = cgo_xgc_fetch_with_cursor(self.handle(), self.curname, &result)
 	if re < 0 {
-		return nil, self.get_error()
+		return nil, self.get_error("XGC_FetchServerCursorRowset", re)
 	}
+	self.result = result
 
-	//re = cgo_xgc_fetch_with_cursor(&self.stmt_conn, self.curname, &self.result)
-	//if re < 0 {
-	//	return nil, self.get_error()
-	//}
+	self.curopend = true
+	self.cursor_seq++
 
-	//self.curopend = true
 	return &xugusqlRows{
-		result:    self.result,
-		prepared:  self.prepared,
-		rows_conn: self.stmt_conn,
+		result:     self.result,
+		prepared:   self.prepared,
+		rows_conn:  self.stmt_conn,
+		curname:    self.curname,
+		stmt:       self,
+		cursor_seq: self.cursor_seq,
+		loc:        self.owner.loc,
 	}, nil
-
 }
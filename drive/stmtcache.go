@@ -0,0 +1,132 @@
+package drive
+
+import (
+	"C"
+	"container/list"
+	"unsafe"
+)
+
+/*
+ * stmtCache keeps the statements prepared on a connection, keyed by
+ * their SQL text, so that preparing the same SQL again reuses the
+ * prepared handle instead of calling XGC_Prepare2. A handle is used by
+ * one statement at a time; once the statement is closed it stays
+ * prepared in the cache, and the least recently used idle handles
+ * beyond the size of the cache are released with XGC_UnPrepare.
+ */
+type stmtCache struct {
+	size    int
+	order   *list.List // of *cachedStmt, most recently used first
+	entries map[string]*list.Element
+}
+
+type cachedStmt struct {
+	query   string
+	prename *C.char
+	inUse   bool
+}
+
+func newStmtCache(size int) *stmtCache {
+	return &stmtCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// Take the idle prepared handle of query, nil if there is none
+func (self *stmtCache) take(query string) *cachedStmt {
+	elem, ok := self.entries[query]
+	if !ok {
+		return nil
+	}
+
+	entry := elem.Value.(*cachedStmt)
+	if entry.inUse {
+		return nil
+	}
+
+	entry.inUse = true
+	self.order.MoveToFront(elem)
+	return entry
+}
+
+// Add a handle just prepared for query, in use by its statement. The
+// handle is not cached, nil is returned, when query already has one.
+func (self *stmtCache) add(query string, prename *C.char) *cachedStmt {
+	if _, ok := self.entries[query]; ok {
+		return nil
+	}
+
+	entry := &cachedStmt{query: query, prename: prename, inUse: true}
+	self.entries[query] = self.order.PushFront(entry)
+	return entry
+}
+
+// Give back the handle of a closed statement and return the idle
+// handles evicted to keep the cache within its size
+func (self *stmtCache) put(entry *cachedStmt) []*cachedStmt {
+	entry.inUse = false
+
+	var evicted []*cachedStmt
+	for elem := self.order.Back(); elem != nil && self.order.Len() > self.size; {
+		prev := elem.Prev()
+
+		if victim := elem.Value.(*cachedStmt); !victim.inUse {
+			self.order.Remove(elem)
+			delete(self.entries, victim.query)
+			evicted = append(evicted, victim)
+		}
+
+		elem = prev
+	}
+
+	return evicted
+}
+
+// Empty the cache and return all its handles
+func (self *stmtCache) clear() []*cachedStmt {
+	var entries []*cachedStmt
+	for elem := self.order.Front(); elem != nil; elem = elem.Next() {
+		entries = append(entries, elem.Value.(*cachedStmt))
+	}
+
+	self.order.Init()
+	self.entries = make(map[string]*list.Element)
+	return entries
+}
+
+// Release the handles evicted from the statement cache
+func (self *xugusqlConn) unprepare(entries []*cachedStmt) error {
+	var err error
+
+	for _, entry := range entries {
+		re := cgo_xgc_unprepare(self.handle(), entry.prename)
+		if re < 0 && err == nil {
+			err = self.get_error("XGC_UnPrepare", re)
+		}
+		cgo_c_free(unsafe.Pointer(entry.prename))
+	}
+
+	return err
+}
+
+/*
+ * prepareCached prepares query through the statement cache, for the
+ * Exec and Query calls made without a statement. ok is false when the
+ * statement cannot be prepared; it then runs directly.
+ */
+func (self *xugusqlConn) prepareCached(query string) (*xugusqlStmt, bool) {
+	stmt, err := self.Prepare(query)
+	if err != nil {
+		return nil, false
+	}
+
+	xs := stmt.(*xugusqlStmt)
+	if !xs.prepared {
+		xs.close()
+		return nil, false
+	}
+
+	return xs, true
+}
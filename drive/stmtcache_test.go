@@ -0,0 +1,55 @@
+package drive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// Run with -race: the statement cache is used by a call abandoned on
+// cancellation while database/sql closes the connection
+func TestStmtCacheCancel(t *testing.T) {
+	conn := &xugusqlConn{stmtCache: newStmtCache(8)}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	resume := make(chan struct{})
+	go func() {
+		<-started
+		cancel()
+	}()
+
+	_, err := conn.watchCancel(ctx, func() (interface{}, error) {
+		close(started)
+		<-resume
+
+		for i := 0; i < 1000; i++ {
+			query := fmt.Sprintf("SELECT %d", i%8)
+
+			entry := conn.stmtCache.take(query)
+			if entry == nil {
+				entry = conn.stmtCache.add(query, cgo_c_calloc(PREPARE_NAME_BUFF_SIZE))
+			}
+
+			stmt := &xugusqlStmt{owner: conn, cached: entry, prename: entry.prename, prepared: true}
+			if err := stmt.close(); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("watchCancel returned %v, want context.Canceled", err)
+	}
+
+	// database/sql closes the bad connection while the call still runs
+	abandoned := conn.abandoned
+	if err := conn.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	close(resume)
+	<-abandoned
+	<-conn.closed
+}
@@ -0,0 +1,171 @@
+package drive
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The text forms of the date and time data types. When parsing, the
+// seconds may be followed by a fraction and the time by an offset
+const (
+	dateLayout     = "2006-01-02"
+	timeLayout     = "15:04:05"
+	datetimeLayout = "2006-01-02 15:04:05"
+
+	// Values are bound with microseconds, the server rounds
+	// them to the precision of the column
+	bindLayout = "2006-01-02 15:04:05.999999"
+)
+
+// The offsets a TIME WITH TIME ZONE or DATETIME WITH TIME ZONE value
+// may end with, "+08:00", "+0800", "+08" or "Z"
+var zoneLayouts = []string{"Z07:00", " Z07:00", "Z0700", " Z0700", "Z07", " Z07"}
+
+// The location of the values read without a zone, UTC unless the
+// session time zone is configured
+func location(loc *time.Location) *time.Location {
+	if loc == nil {
+		return time.UTC
+	}
+	return loc
+}
+
+/*
+ * formatTime writes t in the session time zone with its fractional
+ * seconds. No offset is written, the server reads the value in the
+ * session time zone, which is also the one of TIME WITH TIME ZONE and
+ * DATETIME WITH TIME ZONE columns. Without a configured time zone the
+ * session keeps the server setting and t is written in UTC, the zone
+ * the values without an offset are read in.
+ */
+func formatTime(t time.Time, loc *time.Location) string {
+	return t.In(location(loc)).Format(bindLayout)
+}
+
+/*
+ * parseTime parses the text of a date or time value. Values without an
+ * offset are in the session time zone; those of the WITH TIME ZONE types
+ * keep the offset the server returns.
+ */
+func parseTime(layout string, text string, loc *time.Location) (time.Time, error) {
+
+	text = strings.TrimSpace(text)
+
+	tv, err := time.ParseInLocation(layout, text, location(loc))
+	if err == nil {
+		return tv, nil
+	}
+
+	if layout != dateLayout {
+		for _, zone := range zoneLayouts {
+			if tv, zerr := time.ParseInLocation(layout+zone, text, location(loc)); zerr == nil {
+				return tv, nil
+			}
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("cannot parse %q as a %s value", text, layoutName(layout))
+}
+
+func layoutName(layout string) string {
+	switch layout {
+	case dateLayout:
+		return "DATE"
+	case timeLayout:
+		return "TIME"
+	}
+	return "DATETIME"
+}
+
+/*
+ * parseLocation parses the value of the TIME_ZONE option: a name of the
+ * IANA time zone database such as "Asia/Shanghai", "Local", "UTC", or a
+ * fixed offset such as "GMT+08:00".
+ */
+func parseLocation(value string) (*time.Location, error) {
+
+	upper := strings.ToUpper(value)
+	if !strings.HasPrefix(upper, "GMT") || len(upper) == 3 {
+		loc, err := time.LoadLocation(value)
+		if err != nil {
+			return nil, err
+		}
+		return fixedZone(loc)
+	}
+
+	offset := upper[3:]
+	sign := 1
+	switch offset[0] {
+	case '+':
+	case '-':
+		sign = -1
+	default:
+		return nil, fmt.Errorf("bad time zone offset %q", value)
+	}
+
+	hours, minutes, colon := strings.Cut(offset[1:], ":")
+	if !isDigits(hours, 2) || (colon && !isDigits(minutes, 2)) {
+		return nil, fmt.Errorf("bad time zone offset %q", value)
+	}
+
+	h, _ := strconv.Atoi(hours)
+	if h > 14 {
+		return nil, fmt.Errorf("bad time zone offset %q", value)
+	}
+
+	m := 0
+	if colon {
+		m, _ = strconv.Atoi(minutes)
+		if m >= 60 {
+			return nil, fmt.Errorf("bad time zone offset %q", value)
+		}
+	}
+
+	return time.FixedZone(value, sign*(h*3600+m*60)), nil
+}
+
+// Whether text is made of 1 to max decimal digits
+func isDigits(text string, max int) bool {
+	if text == "" || len(text) > max {
+		return false
+	}
+	for _, c := range text {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+ * fixedZone returns loc as a zone of its current, fixed offset. The
+ * session time zone is a fixed offset: a zone observing daylight saving
+ * time would place the values on the other side of a transition an hour
+ * off, it is rejected in favor of an offset such as "GMT+08:00".
+ */
+func fixedZone(loc *time.Location) (*time.Location, error) {
+	year := time.Now().Year()
+	_, winter := time.Date(year, time.January, 1, 0, 0, 0, 0, loc).Zone()
+	_, summer := time.Date(year, time.July, 1, 0, 0, 0, 0, loc).Zone()
+
+	if winter != summer {
+		return nil, fmt.Errorf("time zone %s observes daylight saving time, use a fixed offset such as GMT+08:00", loc)
+	}
+
+	return time.FixedZone(loc.String(), winter), nil
+}
+
+// The XGC_ATTR_TIMEZONE value of a location, the offset it has now
+func sessionTimeZone(loc *time.Location) string {
+	_, offset := time.Now().In(loc).Zone()
+
+	sign := '+'
+	if offset < 0 {
+		sign = '-'
+		offset = -offset
+	}
+
+	return fmt.Sprintf("GMT%c%02d:%02d", sign, offset/3600, offset%3600/60)
+}
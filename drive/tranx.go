@@ -1,6 +1,7 @@
 package drive
 
 import (
+	"database/sql/driver"
 	"errors"
 )
 
@@ -12,12 +13,16 @@ func (self *xugusqlTx) Commit() error {
 	if self.tconn == nil {
 		return errors.New("Invalid connection")
 	}
-	err := self.tconn.exec("commit;")
+
+	if self.tconn.bad {
+		return driver.ErrBadConn
+	}
+	err := self.tconn.checkBad(self.tconn.exec("commit;"))
 	if err != nil {
 		return err
 	}
 
-	return self.tconn.exec("set auto_commit on;")
+	return self.finish()
 }
 
 func (self *xugusqlTx) Rollback() error {
@@ -25,11 +30,25 @@ func (self *xugusqlTx) Rollback() error {
 	if self.tconn == nil {
 		return errors.New("Invalid connection")
 	}
-	err := self.tconn.exec("rollback;")
+
+	if self.tconn.bad {
+		return driver.ErrBadConn
+	}
+	err := self.tconn.checkBad(self.tconn.exec("rollback;"))
 	if err != nil {
 		return err
 	}
 
-	return self.tconn.exec("set auto_commit on;")
+	return self.finish()
+}
+
+// Turn autocommit back on and restore the isolation level of the session
+func (self *xugusqlTx) finish() error {
+	err := self.tconn.checkBad(self.tconn.exec("set auto_commit on;"))
+	if err != nil {
+		return err
+	}
+	self.tconn.autocommitOff = false
 
+	return self.tconn.restoreIsolation()
 }
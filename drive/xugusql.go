@@ -4,7 +4,6 @@ import (
 	"context"
 	"database/sql"
 	"database/sql/driver"
-	"time"
 )
 
 // XuguDriver is exported to make the driver directly accessible
@@ -18,8 +17,6 @@ func init() {
 	 * it panics.
 	 */
 	sql.Register("xugusql", &XuguDriver{})
-	timezone, _ := time.LoadLocation("Asia/Shanghai")
-	time.Local = timezone
 }
 
 // Open opens a database specified by its database driver name and a
@@ -39,10 +36,19 @@ func init() {
 // function should be called just once. It is rarely necessary to
 // close a DB.
 func (db XuguDriver) Open(dsn string) (driver.Conn, error) {
-	conn := &connector{dsn: dsn}
+	conn, err := db.OpenConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
 	return conn.Connect(context.Background())
 }
 
+// OpenConnector parses the data source name once, the returned
+// connector opens every connection of a sql.DB with it.
 func (db XuguDriver) OpenConnector(dsn string) (driver.Connector, error) {
-	return &connector{dsn: dsn}, nil
+	cfg, err := ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return newConnector(cfg), nil
 }